@@ -43,4 +43,5 @@ func run(command *cobra.Command, args []string) error {
 func init() {
 	// Define flags for configuring the Manual Approval
 	cmd.Flags().StringVar(&cfg.Handler, "handler", "", "Handler field allows you to choose particular handler in the manual approval custom job.")
+	cmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "Print the API requests, status and outputs of the handler instead of sending and writing them.")
 }
@@ -100,13 +100,13 @@ func Test_arguments(t *testing.T) {
 		{
 			name: "cancel - no URL environment variable",
 			args: []string{"manual-approval", "--handler", "cancel"},
-			env:  map[string]string{"CANCELLATION_REASON": "test reason"},
+			env:  map[string]string{"CANCELLATION_REASON": "test reason", "CLOUDBEES_STATUS": "/tmp/fake-status.out" + strconv.Itoa(time.Now().Nanosecond())},
 			err:  "URL environment variable missing",
 		},
 		{
 			name: "cancel - no API_TOKEN environment variable",
 			args: []string{"manual-approval", "--handler", "cancel"},
-			env:  map[string]string{"CANCELLATION_REASON": "test reason", "URL": "http://test.com"},
+			env:  map[string]string{"CANCELLATION_REASON": "test reason", "URL": "http://test.com", "CLOUDBEES_STATUS": "/tmp/fake-status.out" + strconv.Itoa(time.Now().Nanosecond())},
 			err:  "API_TOKEN environment variable missing",
 		},
 	}
@@ -0,0 +1,278 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+)
+
+// Maximum size of an approvers file referenced with @file
+const maxApproversFileSize = 64 * 1024
+
+// Kinds of approvers, which can be given explicitly with the team: and user: prefixes
+const (
+	approverKindUser = "user"
+	approverKindTeam = "team"
+)
+
+// approver is a single user or team requested to approve. Approvers without a prefix are
+// users, but their kind is not sent to the platform, which resolves them as they were given.
+type approver struct {
+	kind     string
+	name     string
+	prefixed bool
+}
+
+func (a approver) String() string {
+	return a.kind + ":" + a.name
+}
+
+// Get the approver as written to the job log, which keeps the kind of prefixed approvers
+func (a approver) displayName() string {
+	if a.prefixed {
+		return a.String()
+	}
+	return a.name
+}
+
+// Add an approver, unless an approver of the same kind and name was already added. An approver
+// given with and without a prefix is added once, with its kind sent to the platform.
+func addApprover(approvers []approver, a approver) []approver {
+	for i, added := range approvers {
+		if added.kind == a.kind && added.name == a.name {
+			approvers[i].prefixed = added.prefixed || a.prefixed
+			return approvers
+		}
+	}
+	return append(approvers, a)
+}
+
+// Parse the comma or newline separated approvers. Entries can be user IDs, emails or team names,
+// optionally prefixed with team: or user:, or @file references to a CODEOWNERS-like file in the
+// workspace. Emails are lower-cased and duplicate approvers of the same kind are dropped.
+func parseApprovers(value string) ([]approver, error) {
+	var approvers []approver
+	add := func(a approver) {
+		approvers = addApprover(approvers, a)
+	}
+
+	for i, entry := range parseList(value) {
+		if path, ok := strings.CutPrefix(entry, "@"); ok && !strings.Contains(path, "@") {
+			owners, err := readApproversFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid approvers entry %d '%s': %w", i+1, entry, err)
+			}
+			for _, a := range owners {
+				add(a)
+			}
+			continue
+		}
+
+		a, err := parseApprover(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid approvers entry %d '%s': %w", i+1, entry, err)
+		}
+		add(a)
+	}
+	return approvers, nil
+}
+
+func parseApprover(entry string) (approver, error) {
+	if strings.ContainsAny(entry, " \t") {
+		return approver{}, fmt.Errorf("approvers must not contain whitespace")
+	}
+
+	kind, name, prefixed := strings.Cut(entry, ":")
+	if !prefixed {
+		kind, name = approverKindUser, entry
+	}
+	switch kind {
+	case approverKindUser, approverKindTeam:
+	default:
+		return approver{}, fmt.Errorf("unsupported prefix '%s:', valid prefixes are: %s:, %s:", kind, approverKindTeam, approverKindUser)
+	}
+	if name == "" {
+		return approver{}, fmt.Errorf("%s name is missing", kind)
+	}
+
+	if kind == approverKindUser && strings.Contains(name, "@") {
+		address, err := mail.ParseAddress(name)
+		if err != nil || address.Address != name || address.Name != "" {
+			return approver{}, fmt.Errorf("invalid email address")
+		}
+		name = strings.ToLower(name)
+	}
+	return approver{kind: kind, name: name, prefixed: prefixed}, nil
+}
+
+// Read the owners listed in a CODEOWNERS-like file. Each line lists owners separated by whitespace,
+// optionally after a path pattern, and # starts a comment. Owners in the form @org/team are teams,
+// @name are users, and emails are users as well.
+func readApproversFile(path string) ([]approver, error) {
+	data, err := readWorkspaceFile(path, maxApproversFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var approvers []approver
+	for n, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		// skip the path pattern of CODEOWNERS entries
+		if len(fields) > 0 && !strings.Contains(fields[0], "@") && !strings.Contains(fields[0], ":") {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			a, err := parseOwner(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid owner '%s': %w", path, n+1, field, err)
+			}
+			approvers = append(approvers, a)
+		}
+	}
+	return approvers, nil
+}
+
+// Parse an owner of a CODEOWNERS-like file
+func parseOwner(field string) (approver, error) {
+	entry := field
+	if owner, ok := strings.CutPrefix(field, "@"); ok {
+		if strings.Contains(owner, "/") {
+			entry = approverKindTeam + ":" + owner
+		} else {
+			entry = approverKindUser + ":" + owner
+		}
+	}
+	return parseApprover(entry)
+}
+
+// Get the names of the approvers as written to the job log and the approval state
+func approverNames(approvers []approver) []string {
+	names := make([]string, len(approvers))
+	for i, a := range approvers {
+		names[i] = a.displayName()
+	}
+	return names
+}
+
+// Get the names of the approvers as sent to the platform API, which only accepts user IDs,
+// emails and team names. A user and a team of the same name are sent once.
+func approverApiNames(approvers []approver) []string {
+	var names []string
+	for _, a := range approvers {
+		if !slices.Contains(names, a.name) {
+			names = append(names, a.name)
+		}
+	}
+	return names
+}
+
+// Get the kinds of the prefixed approvers, which are sent to the platform API separately from
+// their names
+func approverKinds(approvers []approver) []ApproverKind {
+	var kinds []ApproverKind
+	for _, a := range approvers {
+		if a.prefixed {
+			kinds = append(kinds, ApproverKind{Name: a.name, Kind: a.kind})
+		}
+	}
+	return kinds
+}
+
+// Supported modes for validating the approvers before the approval is requested
+const (
+	approverValidationOff  = "off"
+	approverValidationWarn = "warn"
+	approverValidationFail = "fail"
+)
+
+func parseApproverValidation(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", "false", approverValidationOff:
+		return approverValidationOff, nil
+	case approverValidationWarn:
+		return approverValidationWarn, nil
+	case "true", approverValidationFail:
+		return approverValidationFail, nil
+	default:
+		return "", fmt.Errorf("unsupported validateApprovers value '%s', valid values are: %s, %s, %s", value, approverValidationOff, approverValidationWarn, approverValidationFail)
+	}
+}
+
+// Resolve the requested approvers through the platform API and report the ones which are
+// unknown or not permitted to approve, failing or warning depending on the mode. The platform
+// may not provide the validation endpoint, which is reported the same way.
+func (k *Config) validateApprovers(approvers []string, mode string) error {
+	if mode == approverValidationOff || len(approvers) == 0 {
+		return nil
+	}
+	if k.DryRun {
+		k.Output.Printf("DRY RUN: approvers are not validated\n")
+		return nil
+	}
+
+	resp, err := k.request("POST", "/v1/workflows/approval/approvers/validate", map[string]interface{}{
+		"approvers": approvers,
+	})
+	if isNotSupported(err) {
+		if mode == approverValidationWarn {
+			k.Output.Printf("WARNING: The approvers are not validated as the platform API does not support validating approvers\n")
+			return nil
+		}
+		return fmt.Errorf("failed to validate approvers: the platform API does not support validating approvers, set validateApprovers to 'warn' or 'off'")
+	}
+	if err != nil {
+		k.Output.Printf("ERROR: API response: '%s'\n", resp)
+		return fmt.Errorf("failed to validate approvers: %w", err)
+	}
+	logger.Debug("Response", "response", resp)
+
+	parsedResp := ValidateApproversResponse{}
+	if err := json.Unmarshal([]byte(resp), &parsedResp); err != nil {
+		return fmt.Errorf("failed to validate approvers: %w", err)
+	}
+	resolved := make(map[string]ValidatedApprover, len(parsedResp.Approvers))
+	for _, approver := range parsedResp.Approvers {
+		resolved[approver.Approver] = approver
+	}
+
+	var problems []string
+	k.Output.Printf("Requested approvers:\n")
+	for _, requested := range approvers {
+		approver, ok := resolved[requested]
+		switch {
+		case !ok || !approver.Found:
+			k.Output.Printf(" %s -> not found\n", requested)
+			problems = append(problems, fmt.Sprintf("approver '%s' not found", requested))
+		case !approver.HasExecutePermission:
+			k.Output.Printf(" %s -> %s, no execute permission\n", requested, approver.displayName())
+			problems = append(problems, fmt.Sprintf("approver '%s' has no execute permission for approval on the workflow", requested))
+		default:
+			k.Output.Printf(" %s -> %s\n", requested, approver.displayName())
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	if mode == approverValidationWarn {
+		for _, problem := range problems {
+			k.Output.Printf("WARNING: %s\n", problem)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid approvers: %s", strings.Join(problems, ", "))
+}
+
+func (a ValidatedApprover) displayName() string {
+	if a.Type == "team" {
+		return fmt.Sprintf("team %s", a.TeamName)
+	}
+	if a.Email != "" {
+		return fmt.Sprintf("%s <%s>", a.UserName, a.Email)
+	}
+	return a.UserName
+}
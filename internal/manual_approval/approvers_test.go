@@ -0,0 +1,296 @@
+package manual_approval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseApprovers(t *testing.T) {
+	workspace := t.TempDir()
+	files := map[string]string{
+		"APPROVERS":  "# release owners\n@org/release-team\n\n* Dev@Mail.com @jdoe # leads\n/docs/ @org/docs team:ops\n",
+		"INVALID":    "* @org/release-team\n* not@an@email\n",
+		"UNEXPECTED": "* group:ops\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, name), []byte(content), 0644))
+	}
+
+	tests := []struct {
+		name      string
+		approvers string
+		output    []string
+		err       string
+	}{
+		{
+			name:      "empty",
+			approvers: "",
+			output:    []string{},
+		},
+		{
+			name:      "comma and newline separated",
+			approvers: " 123 ,, User@Mail.com\nteam:ops\nuser:456 , 123,user@mail.com",
+			output:    []string{"user:123", "user:user@mail.com", "team:ops", "user:456"},
+		},
+		{
+			name:      "same approver with and without prefix",
+			approvers: "user:Foo@x.com,foo@x.com,ops,user:ops,team:ops",
+			output:    []string{"user:foo@x.com", "user:ops", "team:ops"},
+		},
+		{
+			name:      "file reference",
+			approvers: "team:ops,@APPROVERS",
+			output:    []string{"team:ops", "team:org/release-team", "user:dev@mail.com", "user:jdoe", "team:org/docs"},
+		},
+		{
+			name:      "unsupported prefix",
+			approvers: "123,group:ops",
+			err:       "invalid approvers entry 2 'group:ops': unsupported prefix 'group:', valid prefixes are: team:, user:",
+		},
+		{
+			name:      "missing team name",
+			approvers: "123\nteam:",
+			err:       "invalid approvers entry 2 'team:': team name is missing",
+		},
+		{
+			name:      "whitespace",
+			approvers: "John Doe",
+			err:       "invalid approvers entry 1 'John Doe': approvers must not contain whitespace",
+		},
+		{
+			name:      "invalid email",
+			approvers: "user@mail.com,user@",
+			err:       "invalid approvers entry 2 'user@': invalid email address",
+		},
+		{
+			name:      "invalid owner in file",
+			approvers: "@INVALID",
+			err:       "invalid approvers entry 1 '@INVALID': INVALID:2: invalid owner 'not@an@email': invalid email address",
+		},
+		{
+			name:      "unsupported prefix in file",
+			approvers: "@UNEXPECTED",
+			err:       "invalid approvers entry 1 '@UNEXPECTED': UNEXPECTED:1: invalid owner 'group:ops': unsupported prefix 'group:', valid prefixes are: team:, user:",
+		},
+		{
+			name:      "missing file",
+			approvers: "@MISSING",
+			err:       "invalid approvers entry 1 '@MISSING': open " + filepath.Join(workspace, "MISSING") + ": no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("CLOUDBEES_WORKSPACE", workspace)
+			defer os.Unsetenv("CLOUDBEES_WORKSPACE")
+
+			// Run
+			result, err := parseApprovers(tt.approvers)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				names := []string{}
+				for _, a := range result {
+					names = append(names, a.String())
+				}
+				require.Equal(t, tt.output, names)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_approverApiNames(t *testing.T) {
+	approvers, err := parseApprovers("123,ops,user:ops,team:ops,Jdoe@mail.com,team:org/docs")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"123", "user:ops", "team:ops", "jdoe@mail.com", "team:org/docs"}, approverNames(approvers))
+	require.Equal(t, []string{"123", "ops", "jdoe@mail.com", "org/docs"}, approverApiNames(approvers))
+	require.Equal(t, []ApproverKind{
+		{Name: "ops", Kind: approverKindUser},
+		{Name: "ops", Kind: approverKindTeam},
+		{Name: "org/docs", Kind: approverKindTeam},
+	}, approverKinds(approvers))
+}
+
+func Test_validateApprovers(t *testing.T) {
+	validResp := `{"approvers":[{"approver":"123","found":true,"type":"user","userName":"testUserName","userId":"123","hasExecutePermission":true},{"approver":"user@mail.com","found":true,"type":"user","userName":"testUserName","userId":"123","email":"user@mail.com","hasExecutePermission":true},{"approver":"ops","found":true,"type":"team","teamName":"ops","hasExecutePermission":true}]}`
+	invalidResp := `{"approvers":[{"approver":"123","found":true,"type":"user","userName":"testUserName","userId":"123","hasExecutePermission":true},{"approver":"usr@mail.com","found":false},{"approver":"ops","found":true,"type":"team","teamName":"ops","hasExecutePermission":false}]}`
+
+	tests := []struct {
+		name        string
+		approvers   []string
+		mode        string
+		respGenFunc func() (*http.Response, error)
+		output      []string
+		err         string
+	}{
+		{
+			name:      "off",
+			approvers: []string{"123", "typo"},
+			mode:      approverValidationOff,
+		},
+		{
+			name: "no approvers",
+			mode: approverValidationFail,
+		},
+		{
+			name:      "all approvers resolved",
+			approvers: []string{"123", "user@mail.com", "ops"},
+			mode:      approverValidationFail,
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(validResp)),
+				}, nil
+			},
+			output: []string{
+				"Requested approvers:\n",
+				" 123 -> testUserName\n",
+				" user@mail.com -> testUserName <user@mail.com>\n",
+				" ops -> team ops\n",
+			},
+		},
+		{
+			name:      "warn",
+			approvers: []string{"123", "usr@mail.com", "ops"},
+			mode:      approverValidationWarn,
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(invalidResp)),
+				}, nil
+			},
+			output: []string{
+				"Requested approvers:\n",
+				" 123 -> testUserName\n",
+				" usr@mail.com -> not found\n",
+				" ops -> team ops, no execute permission\n",
+				"WARNING: approver 'usr@mail.com' not found\n",
+				"WARNING: approver 'ops' has no execute permission for approval on the workflow\n",
+			},
+		},
+		{
+			name:      "fail",
+			approvers: []string{"123", "usr@mail.com", "ops"},
+			mode:      approverValidationFail,
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(invalidResp)),
+				}, nil
+			},
+			output: []string{
+				"Requested approvers:\n",
+				" 123 -> testUserName\n",
+				" usr@mail.com -> not found\n",
+				" ops -> team ops, no execute permission\n",
+			},
+			err: "invalid approvers: approver 'usr@mail.com' not found, approver 'ops' has no execute permission for approval on the workflow",
+		},
+		{
+			name:      "api failure",
+			approvers: []string{"123"},
+			mode:      approverValidationWarn,
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 500,
+					Status:     "500 Internal Server Error",
+					Body:       io.NopCloser(bytes.NewBufferString("wrong parameter")),
+				}, nil
+			},
+			output: []string{
+				"ERROR: API response: 'wrong parameter'\n",
+			},
+			err: "failed to validate approvers: failed to send event: \nPOST http://test.com/v1/workflows/approval/approvers/validate\nHTTP/500 500 Internal Server Error\n",
+		},
+		{
+			name:      "warn not supported",
+			approvers: []string{"123"},
+			mode:      approverValidationWarn,
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString("")),
+				}, nil
+			},
+			output: []string{
+				"WARNING: The approvers are not validated as the platform API does not support validating approvers\n",
+			},
+		},
+		{
+			name:      "fail not supported",
+			approvers: []string{"123"},
+			mode:      approverValidationFail,
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString("")),
+				}, nil
+			},
+			err: "failed to validate approvers: the platform API does not support validating approvers, set validateApprovers to 'warn' or 'off'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("URL", "http://test.com")
+			defer os.Unsetenv("URL")
+			os.Setenv("API_TOKEN", "test")
+			defer os.Unsetenv("API_TOKEN")
+
+			var testOutput []string
+
+			// Run
+			c := Config{
+				Client: &MockHttpClient{
+					MockDo: func(req *http.Request) (*http.Response, error) {
+						require.Equal(t, "POST", req.Method)
+						require.Equal(t, "http://test.com/v1/workflows/approval/approvers/validate", req.URL.String())
+
+						reqBody := make(map[string]interface{})
+						bodyReader, err := req.GetBody()
+						require.NoError(t, err)
+						body, err := io.ReadAll(bodyReader)
+						require.NoError(t, err)
+						require.NoError(t, json.Unmarshal(body, &reqBody))
+						require.Len(t, reqBody["approvers"], len(tt.approvers))
+
+						return tt.respGenFunc()
+					},
+				},
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+					},
+				},
+			}
+			err := c.validateApprovers(tt.approvers, tt.mode)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+			require.Equal(t, tt.output, testOutput)
+		})
+	}
+}
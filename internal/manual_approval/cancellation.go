@@ -0,0 +1,102 @@
+package manual_approval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Outcomes of approval requests cancelled for other reasons than an abort or a timeout
+const (
+	outcomeSuperseded   = "superseded"
+	outcomePolicyDenied = "policy_denied"
+	outcomeUnknown      = "unknown"
+)
+
+// cancellation describes how the cancel handler closes an approval request for a cancellation reason
+type cancellation struct {
+	// outcome of the approval request in the metrics, summary and evidence
+	outcome string
+	// status of the approval request sent to the API
+	apiStatus string
+	// status of the job written to CLOUDBEES_STATUS
+	jobStatus string
+	// lines written to the job log
+	messages []string
+	// whether the approval request is decided automatically instead of being cancelled
+	automatic bool
+}
+
+// Cancellation reasons sent by the platform, keyed by the upper case reason. Only the approval
+// statuses of the baseline API are sent, and the jobs end with the FAILED or REJECTED status as
+// the platform has no job status for a cancellation.
+var cancellations = map[string]cancellation{
+	"CANCELLED": {
+		outcome:   outcomeAborted,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+		jobStatus: "FAILED",
+		messages:  []string{"Workflow aborted by user", "Cancelling the manual approval request"},
+	},
+	"TIMED_OUT": {
+		outcome:   outcomeTimedOut,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT",
+		jobStatus: "FAILED",
+		messages:  []string{"Workflow timed out", "Workflow approval response was not received within allotted time."},
+	},
+	"SUPERSEDED": {
+		outcome:   outcomeSuperseded,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+		jobStatus: "FAILED",
+		messages:  []string{"Workflow approval request superseded by a newer request", "Cancelling the manual approval request"},
+	},
+	"POLICY_DENIED": {
+		outcome:   outcomePolicyDenied,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+		jobStatus: "REJECTED",
+		messages:  []string{"Workflow approval request denied by policy", "Cancelling the manual approval request"},
+	},
+}
+
+// Aliases of the cancellation reasons
+var cancellationAliases = map[string]string{
+	"ABORTED": "CANCELLED",
+	"TIMEOUT": "TIMED_OUT",
+}
+
+// Get how to close the approval request for a cancellation reason. Unknown reasons abort the
+// approval request and fail the job, and onTimeout never applies to them, so that an unexpected
+// reason never leads to an automatic approval.
+func cancellationOf(reason string) cancellation {
+	key := strings.ToUpper(strings.TrimSpace(reason))
+	if alias, ok := cancellationAliases[key]; ok {
+		key = alias
+	}
+	if c, ok := cancellations[key]; ok {
+		return c
+	}
+	return cancellation{
+		outcome:   outcomeUnknown,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+		jobStatus: "FAILED",
+		messages:  []string{fmt.Sprintf("WARNING: Workflow cancelled for an unknown reason '%s'", reason), "Cancelling the manual approval request"},
+	}
+}
+
+// Message of the job status of a cancelled approval request
+func (c cancellation) statusMessage() string {
+	switch c.outcome {
+	case outcomeApproved:
+		return "Workflow manual approval request automatically approved after timing out"
+	case outcomeRejected:
+		return "Workflow manual approval request automatically rejected after timing out"
+	case outcomeAborted:
+		return "Workflow manual approval request aborted"
+	case outcomeTimedOut:
+		return "Workflow manual approval request timed out"
+	case outcomeSuperseded:
+		return "Workflow manual approval request superseded by a newer request"
+	case outcomePolicyDenied:
+		return "Workflow manual approval request denied by policy"
+	default:
+		return "Workflow manual approval request cancelled for an unknown reason"
+	}
+}
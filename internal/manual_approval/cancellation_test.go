@@ -0,0 +1,87 @@
+package manual_approval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_cancellationOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		reason    string
+		outcome   string
+		apiStatus string
+		jobStatus string
+		message   string
+	}{
+		{
+			name:      "aborted",
+			reason:    "CANCELLED",
+			outcome:   outcomeAborted,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+			jobStatus: "FAILED",
+			message:   "Workflow manual approval request aborted",
+		},
+		{
+			name:      "aborted alias",
+			reason:    "aborted",
+			outcome:   outcomeAborted,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+			jobStatus: "FAILED",
+			message:   "Workflow manual approval request aborted",
+		},
+		{
+			name:      "timed out",
+			reason:    "TIMED_OUT",
+			outcome:   outcomeTimedOut,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT",
+			jobStatus: "FAILED",
+			message:   "Workflow manual approval request timed out",
+		},
+		{
+			name:      "timed out alias",
+			reason:    " Timeout ",
+			outcome:   outcomeTimedOut,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT",
+			jobStatus: "FAILED",
+			message:   "Workflow manual approval request timed out",
+		},
+		{
+			name:      "superseded",
+			reason:    "SUPERSEDED",
+			outcome:   outcomeSuperseded,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+			jobStatus: "FAILED",
+			message:   "Workflow manual approval request superseded by a newer request",
+		},
+		{
+			name:      "policy denied",
+			reason:    "POLICY_DENIED",
+			outcome:   outcomePolicyDenied,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+			jobStatus: "REJECTED",
+			message:   "Workflow manual approval request denied by policy",
+		},
+		{
+			name:      "unknown",
+			reason:    "",
+			outcome:   outcomeUnknown,
+			apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED",
+			jobStatus: "FAILED",
+			message:   "Workflow manual approval request cancelled for an unknown reason",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			c := cancellationOf(tt.reason)
+
+			// Verify
+			require.Equal(t, tt.outcome, c.outcome)
+			require.Equal(t, tt.apiStatus, c.apiStatus)
+			require.Equal(t, tt.jobStatus, c.jobStatus)
+			require.Equal(t, tt.message, c.statusMessage())
+		})
+	}
+}
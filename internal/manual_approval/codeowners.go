@@ -0,0 +1,176 @@
+package manual_approval
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Maximum size of a CODEOWNERS file and of a file listing the changed paths
+const (
+	maxCodeownersSize   = 1024 * 1024
+	maxChangedPathsSize = 1024 * 1024
+)
+
+// codeownersRule is a path pattern of a CODEOWNERS file along with its owners
+type codeownersRule struct {
+	pattern string
+	re      *regexp.Regexp
+	owners  []approver
+}
+
+// ownerGroup is a set of owners, one of whom has to approve the changes to the paths they own
+type ownerGroup struct {
+	owners []approver
+	paths  []string
+}
+
+// Parse a CODEOWNERS file in the workspace. Each line is a path pattern followed by its owners
+// separated by whitespace, and # starts a comment.
+func readCodeowners(path string) ([]codeownersRule, error) {
+	data, err := readWorkspaceFile(path, maxCodeownersSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []codeownersRule
+	for n, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern := fields[0]
+		if strings.HasPrefix(pattern, "!") {
+			return nil, fmt.Errorf("%s:%d: negated pattern '%s' is not supported", path, n+1, pattern)
+		}
+		rule := codeownersRule{pattern: pattern, re: codeownersPattern(pattern)}
+		for _, field := range fields[1:] {
+			owner, err := parseOwner(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid owner '%s': %w", path, n+1, field, err)
+			}
+			rule.owners = append(rule.owners, owner)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Convert a CODEOWNERS path pattern, which follows the gitignore rules, to a regular expression
+func codeownersPattern(pattern string) *regexp.Regexp {
+	// a pattern without a slash other than a trailing one matches at any depth
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	directory := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			sb.WriteString("[^/]*")
+		case pattern[i] == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	// a directory pattern only matches the paths below it, a name matches the path itself as well,
+	// and a wildcard in the last segment only matches the files it names
+	lastSegment := pattern[strings.LastIndex(pattern, "/")+1:]
+	switch {
+	case directory:
+		sb.WriteString("/.*$")
+	case strings.ContainsAny(lastSegment, "*?"):
+		sb.WriteString("$")
+	default:
+		sb.WriteString("(/.*)?$")
+	}
+	return regexp.MustCompile(sb.String())
+}
+
+// Get the owners of a path, the last matching rule takes precedence
+func codeownersOf(rules []codeownersRule, path string) ([]approver, bool) {
+	path = strings.TrimPrefix(path, "/")
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].re.MatchString(path) {
+			return rules[i].owners, true
+		}
+	}
+	return nil, false
+}
+
+// Group the changed paths by their owners, in the order the paths are listed.
+// Paths without owners are returned separately.
+func codeownerGroups(rules []codeownersRule, paths []string) ([]ownerGroup, []string) {
+	var groups []ownerGroup
+	var unowned []string
+	for _, path := range paths {
+		owners, ok := codeownersOf(rules, path)
+		if !ok || len(owners) == 0 {
+			unowned = append(unowned, path)
+			continue
+		}
+
+		i := slices.IndexFunc(groups, func(g ownerGroup) bool {
+			return slices.Equal(g.owners, owners)
+		})
+		if i < 0 {
+			groups = append(groups, ownerGroup{owners: owners})
+			i = len(groups) - 1
+		}
+		groups[i].paths = append(groups[i].paths, path)
+	}
+	return groups, unowned
+}
+
+// Get the changed paths listed in the value or in a workspace file, one per line or comma separated
+func changedPaths(value string, file string) ([]string, error) {
+	paths := parseList(value)
+	if file != "" {
+		data, err := readWorkspaceFile(file, maxChangedPathsSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read changed paths file: %w", err)
+		}
+		paths = append(paths, parseList(string(data))...)
+	}
+	return paths, nil
+}
+
+// Request approval from the owners of the changed paths, in addition to the given approvers
+func (k *Config) approversFromCodeowners(approvers []approver, codeowners string, paths []string) ([]approver, []ownerGroup, error) {
+	rules, err := readCodeowners(codeowners)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read approversFromCodeowners: %w", err)
+	}
+
+	groups, unowned := codeownerGroups(rules, paths)
+	for _, path := range unowned {
+		logger.Debug("Changed path has no owners", "path", path)
+	}
+	if len(groups) == 0 {
+		k.Output.Printf("WARNING: none of the %d changed paths has owners in %s\n", len(paths), codeowners)
+		return approvers, nil, nil
+	}
+
+	k.Output.Printf("Approval requested from each of the following owner groups:\n")
+	for _, group := range groups {
+		k.Output.Printf(" %s (%d changed paths)\n", strings.Join(approverNames(group.owners), ", "), len(group.paths))
+		for _, owner := range group.owners {
+			approvers = addApprover(approvers, owner)
+		}
+	}
+	return approvers, groups, nil
+}
@@ -0,0 +1,155 @@
+package manual_approval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_codeownersPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{pattern: "*", path: "main.go", match: true},
+		{pattern: "*", path: "cmd/root.go", match: true},
+		{pattern: "*.md", path: "README.md", match: true},
+		{pattern: "*.md", path: "docs/guide/intro.md", match: true},
+		{pattern: "*.md", path: "docs/guide.mdx", match: false},
+		{pattern: "/build/", path: "build/logs/out.log", match: true},
+		{pattern: "/build/", path: "src/build/out.log", match: false},
+		{pattern: "build/", path: "src/build/out.log", match: true},
+		{pattern: "build/", path: "build", match: false},
+		{pattern: "docs/*", path: "docs/getting-started.md", match: true},
+		{pattern: "docs/*", path: "docs/build-app/troubleshooting.md", match: false},
+		{pattern: "apps", path: "apps/web/index.ts", match: true},
+		{pattern: "apps", path: "src/apps/web/index.ts", match: true},
+		{pattern: "/apps/github", path: "apps/github/main.go", match: true},
+		{pattern: "/apps/github", path: "apps/github-app/main.go", match: false},
+		{pattern: "**/logs", path: "deeply/nested/logs/out.log", match: true},
+		{pattern: "/src/**/test", path: "src/a/b/test/x_test.go", match: true},
+		{pattern: "/src/**/test", path: "src/test/x_test.go", match: true},
+		{pattern: "file?.txt", path: "file1.txt", match: true},
+		{pattern: "file?.txt", path: "file10.txt", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.pattern, tt.path), func(t *testing.T) {
+			// Run
+			result := codeownersPattern(tt.pattern).MatchString(tt.path)
+
+			// Verify
+			require.Equal(t, tt.match, result)
+		})
+	}
+}
+
+func Test_approversFromCodeowners(t *testing.T) {
+	workspace := t.TempDir()
+	files := map[string]string{
+		"CODEOWNERS":     "# default owners\n*                @org/platform\n/services/api/   @org/api-team API-Lead@mail.com\n/services/api/generated/\n*.md             @org/docs\n",
+		"NEGATED":        "!*.md @org/docs\n",
+		"INVALID_OWNERS": "* @org/platform\n/api/ not@an@email\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, name), []byte(content), 0644))
+	}
+
+	tests := []struct {
+		name       string
+		approvers  []approver
+		codeowners string
+		paths      []string
+		result     []string
+		groups     [][]string
+		output     []string
+		err        string
+	}{
+		{
+			name:       "owner groups",
+			approvers:  []approver{{kind: approverKindUser, name: "123"}},
+			codeowners: "CODEOWNERS",
+			paths:      []string{"services/api/main.go", "go.mod", "services/api/README.md", "services/api/handler.go", "services/api/generated/client.go"},
+			result:     []string{"user:123", "team:org/api-team", "user:api-lead@mail.com", "team:org/platform", "team:org/docs"},
+			groups:     [][]string{{"team:org/api-team", "api-lead@mail.com"}, {"team:org/platform"}, {"team:org/docs"}},
+			output: []string{
+				"Approval requested from each of the following owner groups:\n",
+				" team:org/api-team, api-lead@mail.com (2 changed paths)\n",
+				" team:org/platform (1 changed paths)\n",
+				" team:org/docs (1 changed paths)\n",
+			},
+		},
+		{
+			name:       "owner already requested with a prefix",
+			approvers:  []approver{{kind: approverKindUser, name: "api-lead@mail.com", prefixed: true}},
+			codeowners: "CODEOWNERS",
+			paths:      []string{"services/api/main.go"},
+			result:     []string{"user:api-lead@mail.com", "team:org/api-team"},
+			groups:     [][]string{{"team:org/api-team", "api-lead@mail.com"}},
+			output: []string{
+				"Approval requested from each of the following owner groups:\n",
+				" team:org/api-team, api-lead@mail.com (1 changed paths)\n",
+			},
+		},
+		{
+			name:       "no owners",
+			codeowners: "CODEOWNERS",
+			paths:      []string{"services/api/generated/client.go"},
+			result:     []string{},
+			output: []string{
+				"WARNING: none of the 1 changed paths has owners in CODEOWNERS\n",
+			},
+		},
+		{
+			name:       "negated pattern",
+			codeowners: "NEGATED",
+			err:        "failed to read approversFromCodeowners: NEGATED:1: negated pattern '!*.md' is not supported",
+		},
+		{
+			name:       "invalid owner",
+			codeowners: "INVALID_OWNERS",
+			err:        "failed to read approversFromCodeowners: INVALID_OWNERS:2: invalid owner 'not@an@email': invalid email address",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("CLOUDBEES_WORKSPACE", workspace)
+			defer os.Unsetenv("CLOUDBEES_WORKSPACE")
+
+			var testOutput []string
+
+			// Run
+			c := Config{
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+					},
+				},
+			}
+			result, groups, err := c.approversFromCodeowners(tt.approvers, tt.codeowners, tt.paths)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				names := []string{}
+				for _, a := range result {
+					names = append(names, a.String())
+				}
+				require.Equal(t, tt.result, names)
+				var groupNames [][]string
+				for _, g := range groups {
+					groupNames = append(groupNames, approverNames(g.owners))
+				}
+				require.Equal(t, tt.groups, groupNames)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+			require.Equal(t, tt.output, testOutput)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Look up the approval requests with the concurrency key which are still waiting for a decision.
+// The platform may not provide the lookup, which is returned as an error which isNotSupported.
+func (k *Config) pendingApprovals(key string) ([]PendingApproval, error) {
+	resp, err := k.request("POST", "/v1/workflows/approval/pending", map[string]interface{}{
+		"concurrencyKey": key,
+	})
+	if isNotSupported(err) {
+		return nil, fmt.Errorf("concurrencyKey is ignored as the platform API does not support looking up pending approval requests: %w", err)
+	}
+	if err != nil {
+		k.Output.Printf("ERROR: API response: '%s'\n", resp)
+		return nil, fmt.Errorf("failed to look up pending approval requests: %w", err)
+	}
+	logger.Debug("Response", "response", resp)
+
+	parsedResp := PendingApprovalsResponse{}
+	if err := json.Unmarshal([]byte(resp), &parsedResp); err != nil {
+		return nil, fmt.Errorf("failed to look up pending approval requests: %w", err)
+	}
+	return parsedResp.Approvals, nil
+}
+
+// Supersede the older approval requests by the newer request, so that only the latest request waits
+// for a decision. Each request is superseded through its own endpoint, never through the status
+// endpoint of the job's approval request. As the newer request is already created, failures are
+// written as warnings, and no request is superseded if the platform does not provide the endpoint.
+// Returns the number of superseded approval requests.
+func (k *Config) supersedeApprovals(pending []PendingApproval, approvalId string, approvalUrl string) int {
+	link := approvalUrl
+	if link == "" {
+		link = approvalId
+	}
+
+	superseded := 0
+	for _, approval := range pending {
+		if approval.Id == "" || approval.Id == approvalId {
+			continue
+		}
+		k.Output.Printf("Superseding the pending approval request %s\n", approval.displayName())
+		resp, err := k.post("/v1/workflows/approval/"+url.PathEscape(approval.Id)+"/supersede", map[string]interface{}{
+			"supersededBy": approvalId,
+			"comments":     fmt.Sprintf("Superseded by a newer approval request: %s", link),
+		})
+		if isNotSupported(err) {
+			k.Output.Printf("WARNING: Pending approval requests are not superseded as the platform API does not support superseding approval requests\n")
+			logger.Debug("Response", "response", resp)
+			break
+		}
+		if err != nil {
+			k.Output.Printf("WARNING: Failed to supersede the approval request %s: '%s'\n", approval.Id, err)
+			logger.Debug("Response", "response", resp)
+			continue
+		}
+		superseded++
+	}
+	return superseded
+}
+
+func (a PendingApproval) displayName() string {
+	if a.Url != "" {
+		return fmt.Sprintf("%s (%s)", a.Id, a.Url)
+	}
+	return a.Id
+}
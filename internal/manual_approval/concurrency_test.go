@@ -0,0 +1,331 @@
+package manual_approval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Mock of the platform API which records the requests and answers them by path
+func mockApi(t *testing.T, requests *[]map[string]interface{}, responses map[string]func(req map[string]interface{}) (*http.Response, error)) *MockHttpClient {
+	return &MockHttpClient{
+		MockDo: func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "POST", req.Method)
+
+			reqBody := make(map[string]interface{})
+			bodyReader, err := req.GetBody()
+			require.NoError(t, err)
+			body, err := io.ReadAll(bodyReader)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(body, &reqBody))
+			reqBody["path"] = req.URL.Path
+			*requests = append(*requests, reqBody)
+
+			respGenFunc, ok := responses[req.URL.Path]
+			require.True(t, ok, "unexpected request to %s", req.URL.Path)
+			return respGenFunc(reqBody)
+		},
+	}
+}
+
+func okResponse(body string) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}, nil
+}
+
+func Test_pendingApprovals(t *testing.T) {
+	tests := []struct {
+		name        string
+		respGenFunc func(req map[string]interface{}) (*http.Response, error)
+		pending     []PendingApproval
+		output      []string
+		err         string
+	}{
+		{
+			name: "pending approval requests",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				require.Equal(t, "deploy-production", req["concurrencyKey"])
+				return okResponse(`{"approvals":[{"id":"1111","url":"https://cloudbees.io/runs/1"},{"id":"2222"}]}`)
+			},
+			pending: []PendingApproval{{Id: "1111", Url: "https://cloudbees.io/runs/1"}, {Id: "2222"}},
+		},
+		{
+			name: "no pending approval requests",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{}`)
+			},
+		},
+		{
+			name: "API failure",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 500,
+					Status:     "500 Internal Server Error",
+					Body:       io.NopCloser(bytes.NewBufferString(`wrong parameter`)),
+				}, nil
+			},
+			output: []string{"ERROR: API response: 'wrong parameter'\n"},
+			err:    "failed to look up pending approval requests: failed to send event: \nPOST http://test.com/v1/workflows/approval/pending\nHTTP/500 500 Internal Server Error\n",
+		},
+		{
+			name: "not supported",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString(`not found`)),
+				}, nil
+			},
+			err: "concurrencyKey is ignored as the platform API does not support looking up pending approval requests: failed to send event: \nPOST http://test.com/v1/workflows/approval/pending\nHTTP/404 404 Not Found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("URL", "http://test.com")
+			defer os.Unsetenv("URL")
+			os.Setenv("API_TOKEN", "test")
+			defer os.Unsetenv("API_TOKEN")
+
+			var requests []map[string]interface{}
+			var testOutput []string
+
+			// Run
+			c := Config{
+				Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+					"/v1/workflows/approval/pending": tt.respGenFunc,
+				}),
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+					},
+				},
+			}
+			pending, err := c.pendingApprovals("deploy-production")
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.pending, pending)
+			}
+			require.Equal(t, tt.output, testOutput)
+		})
+	}
+}
+
+func Test_supersedeApprovals(t *testing.T) {
+	// Prepare
+	os.Setenv("URL", "http://test.com")
+	defer os.Unsetenv("URL")
+	os.Setenv("API_TOKEN", "test")
+	defer os.Unsetenv("API_TOKEN")
+
+	var requests []map[string]interface{}
+	var testOutput []string
+	c := Config{
+		Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+			"/v1/workflows/approval/1111/supersede": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{}`)
+			},
+			"/v1/workflows/approval/2222/supersede": func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 409,
+					Status:     "409 Conflict",
+					Body:       io.NopCloser(bytes.NewBufferString(`already decided`)),
+				}, nil
+			},
+		}),
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	superseded := c.supersedeApprovals([]PendingApproval{
+		{Id: "1111", Url: "https://cloudbees.io/runs/1"},
+		{Id: "2222"},
+		{Id: "3333"},
+	}, "3333", "https://cloudbees.io/runs/3")
+
+	// Verify
+	require.Equal(t, 1, superseded)
+	require.Equal(t, []map[string]interface{}{
+		{
+			"path":         "/v1/workflows/approval/1111/supersede",
+			"supersededBy": "3333",
+			"comments":     "Superseded by a newer approval request: https://cloudbees.io/runs/3",
+		},
+		{
+			"path":         "/v1/workflows/approval/2222/supersede",
+			"supersededBy": "3333",
+			"comments":     "Superseded by a newer approval request: https://cloudbees.io/runs/3",
+		},
+	}, requests)
+	require.Equal(t, []string{
+		"Superseding the pending approval request 1111 (https://cloudbees.io/runs/1)\n",
+		"Superseding the pending approval request 2222\n",
+		"WARNING: Failed to supersede the approval request 2222: 'failed to send event: \nPOST http://test.com/v1/workflows/approval/2222/supersede\nHTTP/409 409 Conflict\n'\n",
+	}, testOutput)
+}
+
+func Test_initWithConcurrencyKey(t *testing.T) {
+	// Prepare
+	outputs := t.TempDir()
+	for k, v := range map[string]string{
+		"URL":               "http://test.com",
+		"API_TOKEN":         "test",
+		"CLOUDBEES_STATUS":  filepath.Join(t.TempDir(), "status"),
+		"CLOUDBEES_OUTPUTS": outputs,
+		"APPROVERS":         "123",
+		"CONCURRENCY_KEY":   " deploy-production ",
+	} {
+		os.Setenv(k, v)
+		defer func(k string) {
+			os.Unsetenv(k)
+		}(k)
+	}
+
+	var requests []map[string]interface{}
+	var testOutput []string
+	c := Config{
+		Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+			"/v1/workflows/approval/pending": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{"approvals":[{"id":"1111"}]}`)
+			},
+			"/v1/workflows/approval": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{"id":"2222","approvers":[{"userName":"testUserName","userId":"123"}]}`)
+			},
+			"/v1/workflows/approval/1111/supersede": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{}`)
+			},
+		}),
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	err := c.init()
+
+	// Verify
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	require.Equal(t, "/v1/workflows/approval/pending", requests[0]["path"])
+	require.Equal(t, "deploy-production", requests[0]["concurrencyKey"])
+	require.Equal(t, "/v1/workflows/approval", requests[1]["path"])
+	require.Equal(t, "deploy-production", requests[1]["concurrencyKey"])
+	require.Equal(t, "/v1/workflows/approval/1111/supersede", requests[2]["path"])
+	require.Equal(t, "2222", requests[2]["supersededBy"])
+	require.Equal(t, "Superseded by a newer approval request: 2222", requests[2]["comments"])
+	require.Equal(t, []string{
+		"Waiting for approval from one of the following: testUserName\n",
+		"Superseding the pending approval request 1111\n",
+	}, testOutput)
+}
+
+func Test_supersedeApprovalsNotSupported(t *testing.T) {
+	// Prepare
+	os.Setenv("URL", "http://test.com")
+	defer os.Unsetenv("URL")
+	os.Setenv("API_TOKEN", "test")
+	defer os.Unsetenv("API_TOKEN")
+
+	var requests []map[string]interface{}
+	var testOutput []string
+	c := Config{
+		Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+			"/v1/workflows/approval/1111/supersede": func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString(`not found`)),
+				}, nil
+			},
+		}),
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	superseded := c.supersedeApprovals([]PendingApproval{{Id: "1111"}, {Id: "2222"}}, "3333", "")
+
+	// Verify
+	require.Equal(t, 0, superseded)
+	require.Len(t, requests, 1)
+	require.Equal(t, []string{
+		"Superseding the pending approval request 1111\n",
+		"WARNING: Pending approval requests are not superseded as the platform API does not support superseding approval requests\n",
+	}, testOutput)
+}
+
+func Test_initWithConcurrencyKeyNotSupported(t *testing.T) {
+	// Prepare
+	for k, v := range map[string]string{
+		"URL":               "http://test.com",
+		"API_TOKEN":         "test",
+		"CLOUDBEES_STATUS":  filepath.Join(t.TempDir(), "status"),
+		"CLOUDBEES_OUTPUTS": t.TempDir(),
+		"APPROVERS":         "123",
+		"CONCURRENCY_KEY":   "deploy-production",
+	} {
+		os.Setenv(k, v)
+		defer func(k string) {
+			os.Unsetenv(k)
+		}(k)
+	}
+
+	var requests []map[string]interface{}
+	var testOutput []string
+	c := Config{
+		Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+			"/v1/workflows/approval/pending": func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString(`not found`)),
+				}, nil
+			},
+			"/v1/workflows/approval": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{"id":"2222","approvers":[{"userName":"testUserName","userId":"123"}]}`)
+			},
+		}),
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	err := c.init()
+
+	// Verify
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	require.Equal(t, "/v1/workflows/approval", requests[1]["path"])
+	require.NotContains(t, requests[1], "concurrencyKey")
+	require.Equal(t, []string{
+		"WARNING: concurrencyKey is ignored as the platform API does not support looking up pending approval requests: failed to send event: \nPOST http://test.com/v1/workflows/approval/pending\nHTTP/404 404 Not Found\n\n",
+		"Waiting for approval from one of the following: testUserName\n",
+	}, testOutput)
+}
@@ -0,0 +1,192 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate the when conditions of the approval inputs. A condition can only refer to
+// an input declared before it, which rules out cycles between conditional inputs.
+func validateConditions(defs []inputDefinition) error {
+	declared := make(map[string]inputDefinition)
+	for _, def := range defs {
+		for _, name := range def.conditionInputs() {
+			ref, ok := declared[name]
+			switch {
+			case name == def.Name:
+				return fmt.Errorf("input '%s': when cannot refer to the input itself", def.Name)
+			case !ok:
+				return fmt.Errorf("input '%s': when refers to '%s', which is not declared before it", def.Name, name)
+			case ref.Type == inputTypeSecret:
+				return fmt.Errorf("input '%s': when cannot refer to the secret input '%s'", def.Name, name)
+			}
+
+			values, err := conditionValues(def.When[name])
+			if err != nil {
+				return fmt.Errorf("input '%s': when '%s': %w", def.Name, name, err)
+			}
+			// the options of an optionsFrom are not resolved yet, the values are validated
+			// once they are
+			if ref.OptionsFrom != "" && len(ref.Options) == 0 {
+				continue
+			}
+			for _, value := range values {
+				if err := ref.validateValue(ref.conditionValue(value)); err != nil {
+					return fmt.Errorf("input '%s': invalid when condition: %w", def.Name, err)
+				}
+			}
+		}
+		declared[def.Name] = def
+	}
+	return nil
+}
+
+// Get the names of the inputs the conditions of the input refer to, sorted by name
+func (d inputDefinition) conditionInputs() []string {
+	names := make([]string, 0, len(d.When))
+	for name := range d.When {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Convert the expected value or list of values of a condition to strings
+func conditionValues(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("expected a value or a list of values")
+	}
+
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		// unquoted dates are decoded as timestamps
+		if t, ok := v.(time.Time); ok {
+			if t.Equal(t.Truncate(24 * time.Hour)) {
+				values = append(values, t.Format(dateLayout))
+			} else {
+				values = append(values, t.Format(time.RFC3339))
+			}
+			continue
+		}
+
+		switch v.(type) {
+		case nil, []interface{}, map[string]interface{}:
+			return nil, fmt.Errorf("expected a value or a list of values")
+		}
+		s, err := formatValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// Convert an expected value of a condition to the type of the value provided by the approver
+func (d inputDefinition) conditionValue(value string) interface{} {
+	switch d.Type {
+	case inputTypeNumber:
+		return json.Number(value)
+	case inputTypeBoolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case inputTypeMultichoice:
+		return []interface{}{value}
+	}
+	return value
+}
+
+// Check whether the value provided by the approver is one of the expected values of a condition
+func (d inputDefinition) conditionMatches(expected []string, value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		// a multichoice input matches when any of the picked values is expected
+		for _, item := range v {
+			if d.conditionMatches(expected, item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if d.Type == inputTypeNumber {
+		n, ok := numberValue(value)
+		if !ok {
+			return false
+		}
+		for _, e := range expected {
+			if en, err := strconv.ParseFloat(e, 64); err == nil && en == n {
+				return true
+			}
+		}
+		return false
+	}
+
+	s, err := formatValue(value)
+	return err == nil && slices.Contains(expected, s)
+}
+
+// Get the names of the conditional inputs whose conditions are not met by the values provided
+// by the approver. An input which refers to a hidden input is hidden as well.
+func hiddenInputs(defs []inputDefinition, inputValues map[string]interface{}) map[string]bool {
+	hidden := make(map[string]bool)
+	declared := make(map[string]inputDefinition)
+	for _, def := range defs {
+		for _, name := range def.conditionInputs() {
+			expected, _ := conditionValues(def.When[name])
+			if hidden[name] || !declared[name].conditionMatches(expected, inputValues[name]) {
+				hidden[def.Name] = true
+				break
+			}
+		}
+		declared[def.Name] = def
+	}
+	return hidden
+}
+
+// Describe the conditions of the input for error messages
+func (d inputDefinition) describeConditions() string {
+	var conditions []string
+	for _, name := range d.conditionInputs() {
+		expected, _ := conditionValues(d.When[name])
+		if len(expected) == 1 {
+			conditions = append(conditions, fmt.Sprintf("'%s' is '%s'", name, expected[0]))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("'%s' is one of: %s", name, strings.Join(expected, ", ")))
+		}
+	}
+	return strings.Join(conditions, " and ")
+}
+
+// Remove the values of the conditional inputs whose conditions are not met, so they are
+// neither sent to the API nor written to the outputs
+func dropHiddenInputs(parsedPayload map[string]interface{}, defs []inputDefinition) {
+	hidden := hiddenInputs(defs, payloadInputValues(parsedPayload))
+	if len(hidden) == 0 {
+		return
+	}
+
+	inputs, _ := parsedPayload["inputs"].([]interface{})
+	visible := make([]interface{}, 0, len(inputs))
+	for _, input := range inputs {
+		if ip, ok := input.(map[string]interface{}); ok {
+			if name, _ := ip["name"].(string); hidden[name] {
+				logger.Debug("Ignoring the value of the input as its conditions are not met", "input", name)
+				continue
+			}
+		}
+		visible = append(visible, input)
+	}
+	parsedPayload["inputs"] = visible
+}
@@ -0,0 +1,135 @@
+package manual_approval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs string
+		err    string
+	}{
+		{
+			name:   "valid conditions",
+			inputs: "action:\n  type: choice\n  options: [deploy, rollback]\nretries:\n  type: number\nregions:\n  type: multichoice\n  options: [eu, us]\nwindow:\n  type: date\n  when:\n    action: [deploy, rollback]\n    retries: 3\n    regions: eu\ntarget:\n  type: string\n  required: true\n  when:\n    action: rollback\n    window: 2024-02-29\n",
+		},
+		{
+			name:   "unknown input",
+			inputs: "target:\n  type: string\n  when:\n    action: rollback\n",
+			err:    "input 'target': when refers to 'action', which is not declared before it",
+		},
+		{
+			name:   "input declared later",
+			inputs: "target:\n  type: string\n  when:\n    action: rollback\naction:\n  type: choice\n  options: [deploy, rollback]\n",
+			err:    "input 'target': when refers to 'action', which is not declared before it",
+		},
+		{
+			name:   "input itself",
+			inputs: "target:\n  type: string\n  when:\n    target: v1\n",
+			err:    "input 'target': when cannot refer to the input itself",
+		},
+		{
+			name:   "secret input",
+			inputs: "otp:\n  type: secret\ntarget:\n  type: string\n  when:\n    otp: '123456'\n",
+			err:    "input 'target': when cannot refer to the secret input 'otp'",
+		},
+		{
+			name:   "value not in options",
+			inputs: "action:\n  type: choice\n  options: [deploy, rollback]\ntarget:\n  type: string\n  when:\n    action: restart\n",
+			err:    "input 'target': invalid when condition: input 'action': value must be one of: deploy, rollback",
+		},
+		{
+			name:   "options not resolved yet",
+			inputs: "env:\n  type: choice\n  optionsFrom: envs.json\ntarget:\n  type: string\n  when:\n    env: prod\n",
+		},
+		{
+			name:   "value of the wrong type",
+			inputs: "retries:\n  type: number\ntarget:\n  type: string\n  when:\n    retries: many\n",
+			err:    "input 'target': invalid when condition: input 'retries': expected a number value",
+		},
+		{
+			name:   "no values",
+			inputs: "action:\n  type: choice\n  options: [deploy, rollback]\ntarget:\n  type: string\n  when:\n    action: []\n",
+			err:    "input 'target': when 'action': expected a value or a list of values",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			_, err := parseInputs(tt.inputs)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_conditionalInputValues(t *testing.T) {
+	defs, err := parseInputs("action:\n  type: choice\n  options: [deploy, rollback, restart]\nretries:\n  type: number\nrollback-target:\n  type: string\n  required: true\n  when:\n    action: rollback\nreason:\n  type: string\n  required: true\n  when:\n    action: [rollback, restart]\n    retries: 3\nrollback-note:\n  type: string\n  when:\n    rollback-target: v1\n")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		hidden []string
+		err    string
+	}{
+		{
+			name:   "conditions not met",
+			values: map[string]interface{}{"action": "deploy", "rollback-target": "v1", "rollback-note": "note"},
+			hidden: []string{"reason", "rollback-note", "rollback-target"},
+		},
+		{
+			name:   "conditions met",
+			values: map[string]interface{}{"action": "rollback", "retries": 2.0, "rollback-target": "v1", "reason": "broken", "rollback-note": "note"},
+			hidden: []string{"reason"},
+		},
+		{
+			name:   "number condition met",
+			values: map[string]interface{}{"action": "restart", "retries": 3.0, "reason": "stuck"},
+			hidden: []string{"rollback-note", "rollback-target"},
+		},
+		{
+			name:   "required input missing",
+			values: map[string]interface{}{"action": "rollback"},
+			hidden: []string{"reason", "rollback-note"},
+			err:    "input 'rollback-target' is required when 'action' is 'rollback'",
+		},
+		{
+			name:   "required input empty",
+			values: map[string]interface{}{"action": "restart", "retries": 3.0, "reason": ""},
+			hidden: []string{"rollback-note", "rollback-target"},
+			err:    "input 'reason' is required when 'action' is one of: rollback, restart and 'retries' is '3'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			hidden := hiddenInputs(defs, tt.values)
+			err := validateInputValues(defs, tt.values)
+
+			// Verify
+			var hiddenNames []string
+			for _, def := range []string{"reason", "rollback-note", "rollback-target"} {
+				if hidden[def] {
+					hiddenNames = append(hiddenNames, def)
+				}
+			}
+			require.Equal(t, tt.hidden, hiddenNames)
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported formats of the approval evidence
+const (
+	evidenceFormatJunit = "junit"
+	evidenceFormatJson  = "json"
+)
+
+// Name of the approval gate in the evidence when none is configured
+const defaultEvidenceName = "manual-approval"
+
+// Version of the JSON evidence bundle
+const evidenceVersion = 1
+
+// Parse the comma separated evidence formats
+func parseEvidenceFormats(value string) ([]string, error) {
+	var formats []string
+	for _, format := range parseList(strings.ToLower(value)) {
+		if format != evidenceFormatJunit && format != evidenceFormatJson {
+			return nil, fmt.Errorf("unsupported evidence format '%s', valid values are: %s, %s", format, evidenceFormatJunit, evidenceFormatJson)
+		}
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return formats, nil
+}
+
+// approvalEvidence is the JSON evidence bundle of a decided approval gate
+type approvalEvidence struct {
+	Version    int                    `json:"version"`
+	Name       string                 `json:"name"`
+	ApprovalId string                 `json:"approvalId,omitempty"`
+	Outcome    string                 `json:"outcome"`
+	ReusedFrom string                 `json:"reusedFrom,omitempty"`
+	Request    *evidenceRequest       `json:"request,omitempty"`
+	Response   map[string]interface{} `json:"response"`
+	DecidedOn  time.Time              `json:"decidedOn"`
+}
+
+// evidenceRequest is the approval request as it was sent by the init handler
+type evidenceRequest struct {
+	Approvers          []string   `json:"approvers,omitempty"`
+	ApproverGroups     [][]string `json:"approverGroups,omitempty"`
+	Inputs             string     `json:"inputs,omitempty"`
+	InstructionsSha256 string     `json:"instructionsSha256,omitempty"`
+	RequestedOn        time.Time  `json:"requestedOn"`
+}
+
+// Create the evidence of a decision. The response is the approval status sent to the API,
+// and the request is only known for approvals requested with an approval state.
+func newApprovalEvidence(state *approvalState, outcome string, decidedOn time.Time, response map[string]interface{}) approvalEvidence {
+	name := os.Getenv("EVIDENCE_NAME")
+	if name == "" {
+		name = defaultEvidenceName
+	}
+
+	// the callback token must not end up in the evidence
+	redacted := make(map[string]interface{}, len(response))
+	for key, value := range response {
+		if key != "token" {
+			redacted[key] = value
+		}
+	}
+
+	evidence := approvalEvidence{
+		Version:   evidenceVersion,
+		Name:      name,
+		Outcome:   outcome,
+		Response:  redacted,
+		DecidedOn: decidedOn.UTC(),
+	}
+	if state != nil {
+		evidence.ApprovalId = state.ApprovalId
+		evidence.ReusedFrom = state.ReusedFrom
+		evidence.Request = &evidenceRequest{
+			Approvers:          state.Approvers,
+			ApproverGroups:     state.ApproverGroups,
+			Inputs:             state.Inputs,
+			InstructionsSha256: state.InstructionsSha256,
+			RequestedOn:        state.RequestedOn,
+		}
+	}
+	return evidence
+}
+
+// JUnit XML report with a test case per approval gate
+type junitTestsuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestsuite `xml:"testsuite"`
+}
+
+type junitTestsuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestcase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestcase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	Time      string       `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure,omitempty"`
+	Skipped   *junitResult `xml:"skipped,omitempty"`
+	SystemOut string       `xml:"system-out,omitempty"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Render the evidence as JUnit XML. Approvals which were rejected, timed out, denied by policy
+// or cancelled for an unknown reason are failures, aborted and superseded approvals are skipped.
+func (e approvalEvidence) junit() ([]byte, error) {
+	var duration time.Duration
+	if e.Request != nil {
+		duration = decisionOf(e.Outcome, e.Request.RequestedOn, e.DecidedOn).duration
+	}
+	seconds := strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)
+
+	approver, _ := e.Response["userName"].(string)
+	comments, _ := e.Response["comments"].(string)
+
+	testcase := junitTestcase{Name: e.Name, Classname: defaultEvidenceName, Time: seconds, SystemOut: comments}
+	suite := junitTestsuite{Name: e.Name, Tests: 1, Time: seconds, Timestamp: e.DecidedOn.Format(time.RFC3339)}
+	title := outcomeTitles[e.Outcome]
+	if approver != "" {
+		title += " by " + approver
+	}
+	switch e.Outcome {
+	case outcomeRejected, outcomeTimedOut, outcomePolicyDenied, outcomeUnknown:
+		testcase.Failure = &junitResult{Message: title, Type: e.Outcome, Text: comments}
+		suite.Failures = 1
+	case outcomeAborted, outcomeSuperseded:
+		testcase.Skipped = &junitResult{Message: title}
+		suite.Skipped = 1
+	}
+	suite.Cases = []junitTestcase{testcase}
+
+	for _, p := range []junitProperty{
+		{Name: "approvalId", Value: e.ApprovalId},
+		{Name: "outcome", Value: e.Outcome},
+		{Name: "approver", Value: approver},
+	} {
+		if p.Value != "" {
+			suite.Properties = append(suite.Properties, p)
+		}
+	}
+	if e.Request != nil && e.Request.InstructionsSha256 != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "instructionsSha256", Value: e.Request.InstructionsSha256})
+	}
+
+	data, err := xml.MarshalIndent(junitTestsuites{Suites: []junitTestsuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Write the evidence of the decision in the given formats to the evidence directory
+func (k *Config) writeEvidence(formats []string, state *approvalState, outcome string, decidedOn time.Time, response map[string]interface{}) error {
+	if len(formats) == 0 {
+		return nil
+	}
+
+	dir := os.Getenv("EVIDENCE_DIR")
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := workspacePath(dir)
+	if err != nil {
+		return err
+	}
+
+	// each approval request has its own files, so that several gates can share the directory
+	evidence := newApprovalEvidence(state, outcome, decidedOn, response)
+	name := defaultEvidenceName
+	if evidence.ApprovalId != "" {
+		name += "-" + evidence.ApprovalId
+	}
+
+	for _, format := range formats {
+		var data []byte
+		var path string
+		switch format {
+		case evidenceFormatJunit:
+			data, err = evidence.junit()
+			path = filepath.Join(dir, name+".xml")
+		case evidenceFormatJson:
+			data, err = json.MarshalIndent(evidence, "", "  ")
+			path = filepath.Join(dir, name+".json")
+		}
+		if err != nil {
+			return err
+		}
+
+		if k.DryRun {
+			k.Output.Printf("DRY RUN: evidence '%s':\n%s\n", path, data)
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write to %s: %w", path, err)
+		}
+		k.Output.Printf("Approval evidence written to %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseEvidenceFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		formats []string
+		err     string
+	}{
+		{
+			name:  "none",
+			value: "",
+		},
+		{
+			name:    "both",
+			value:   "JUnit, json,junit",
+			formats: []string{evidenceFormatJunit, evidenceFormatJson},
+		},
+		{
+			name:  "unsupported",
+			value: "junit,sarif",
+			err:   "unsupported evidence format 'sarif', valid values are: junit, json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formats, err := parseEvidenceFormats(tt.value)
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.formats, formats)
+		})
+	}
+}
+
+func Test_junit(t *testing.T) {
+	state := &approvalState{
+		Version:            stateVersion,
+		ApprovalId:         "1234",
+		Approvers:          []string{"123"},
+		InstructionsSha256: instructionsSha256("instructions"),
+		RequestedOn:        time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC),
+	}
+	decidedOn := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		state    *approvalState
+		outcome  string
+		response map[string]interface{}
+		junit    string
+	}{
+		{
+			name:     "approved",
+			state:    state,
+			outcome:  outcomeApproved,
+			response: map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", "userName": "Some One", "comments": "lgtm"},
+			junit: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="manual-approval" tests="1" failures="0" skipped="0" time="3600" timestamp="2009-11-10T23:00:00Z">
+    <properties>
+      <property name="approvalId" value="1234"></property>
+      <property name="outcome" value="approved"></property>
+      <property name="approver" value="Some One"></property>
+      <property name="instructionsSha256" value="238fa28a94976c7da14563bc873c2729bd5cd325389085bb4c6dd0de28923590"></property>
+    </properties>
+    <testcase name="manual-approval" classname="manual-approval" time="3600">
+      <system-out>lgtm</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+		},
+		{
+			name:     "rejected",
+			state:    state,
+			outcome:  outcomeRejected,
+			response: map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", "userName": "Some One", "comments": "<not yet>"},
+			junit: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="manual-approval" tests="1" failures="1" skipped="0" time="3600" timestamp="2009-11-10T23:00:00Z">
+    <properties>
+      <property name="approvalId" value="1234"></property>
+      <property name="outcome" value="rejected"></property>
+      <property name="approver" value="Some One"></property>
+      <property name="instructionsSha256" value="238fa28a94976c7da14563bc873c2729bd5cd325389085bb4c6dd0de28923590"></property>
+    </properties>
+    <testcase name="manual-approval" classname="manual-approval" time="3600">
+      <failure message="Rejected by Some One" type="rejected">&lt;not yet&gt;</failure>
+      <system-out>&lt;not yet&gt;</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+		},
+		{
+			name:     "timed out without approval state",
+			outcome:  outcomeTimedOut,
+			response: map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT"},
+			junit: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="manual-approval" tests="1" failures="1" skipped="0" time="0" timestamp="2009-11-10T23:00:00Z">
+    <properties>
+      <property name="outcome" value="timed_out"></property>
+    </properties>
+    <testcase name="manual-approval" classname="manual-approval" time="0">
+      <failure message="Timed out" type="timed_out"></failure>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+		},
+		{
+			name:     "aborted",
+			state:    state,
+			outcome:  outcomeAborted,
+			response: map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED"},
+			junit: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="manual-approval" tests="1" failures="0" skipped="1" time="3600" timestamp="2009-11-10T23:00:00Z">
+    <properties>
+      <property name="approvalId" value="1234"></property>
+      <property name="outcome" value="aborted"></property>
+      <property name="instructionsSha256" value="238fa28a94976c7da14563bc873c2729bd5cd325389085bb4c6dd0de28923590"></property>
+    </properties>
+    <testcase name="manual-approval" classname="manual-approval" time="3600">
+      <skipped message="Aborted"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			junit, err := newApprovalEvidence(tt.state, tt.outcome, decidedOn, tt.response).junit()
+			require.NoError(t, err)
+			require.Equal(t, tt.junit, string(junit))
+		})
+	}
+}
+
+func Test_writeEvidence(t *testing.T) {
+	// Prepare
+	workspace := t.TempDir()
+	env := map[string]string{
+		"CLOUDBEES_WORKSPACE": workspace,
+		"EVIDENCE_DIR":        "evidence",
+		"EVIDENCE_NAME":       "production-deploy",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer func(k string) {
+			os.Unsetenv(k)
+		}(k)
+	}
+	state := &approvalState{
+		Version:            stateVersion,
+		ApprovalId:         "1234",
+		Approvers:          []string{"123"},
+		Inputs:             "in1:\n  type: string\n",
+		Instructions:       "instructions",
+		InstructionsSha256: instructionsSha256("instructions"),
+		RequestedOn:        time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC),
+	}
+	response := map[string]interface{}{
+		"status":   "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+		"userName": "Some One",
+		"comments": "lgtm",
+		"token":    "test-callback-token",
+		"inputs":   []interface{}{map[string]interface{}{"name": "in1", "value": "abc"}},
+	}
+
+	var testOutput []string
+	c := Config{
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	err := c.writeEvidence([]string{evidenceFormatJunit, evidenceFormatJson}, state, outcomeApproved, time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC), response)
+
+	// Verify
+	require.NoError(t, err)
+	junitFile := filepath.Join(workspace, "evidence", "manual-approval-1234.xml")
+	jsonFile := filepath.Join(workspace, "evidence", "manual-approval-1234.json")
+	require.Equal(t, []string{
+		"Approval evidence written to " + junitFile + "\n",
+		"Approval evidence written to " + jsonFile + "\n",
+	}, testOutput)
+
+	out, err := os.ReadFile(junitFile)
+	require.NoError(t, err)
+	require.Contains(t, string(out), `<testcase name="production-deploy" classname="manual-approval" time="3600">`)
+
+	out, err = os.ReadFile(jsonFile)
+	require.NoError(t, err)
+	evidence := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(out, &evidence))
+	require.Equal(t, map[string]interface{}{
+		"version":    1.0,
+		"name":       "production-deploy",
+		"approvalId": "1234",
+		"outcome":    "approved",
+		"request": map[string]interface{}{
+			"approvers":          []interface{}{"123"},
+			"inputs":             "in1:\n  type: string\n",
+			"instructionsSha256": "238fa28a94976c7da14563bc873c2729bd5cd325389085bb4c6dd0de28923590",
+			"requestedOn":        "2009-11-10T22:00:00Z",
+		},
+		"response": map[string]interface{}{
+			"status":   "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			"userName": "Some One",
+			"comments": "lgtm",
+			"inputs":   []interface{}{map[string]interface{}{"name": "in1", "value": "abc"}},
+		},
+		"decidedOn": "2009-11-10T23:00:00Z",
+	}, evidence)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,10 +16,16 @@ import (
 	"time"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var debug bool
 
+// Markdown converter with the GitHub-flavored markdown extensions (tables, task lists, strikethrough and autolinks)
+var md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
 type RealHttpClient struct{}
 
 func (c *RealHttpClient) Do(req *http.Request) (*http.Response, error) {
@@ -48,6 +55,36 @@ func (k *Config) Run(ctx context.Context) error {
 		k.Output = &RealStdOut{}
 	}
 
+	// diagnostic logs are written to the same output, with sensitive values masked
+	l, err := newLogger(k.Output, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"), os.Getenv("LOG_REDACT_KEYS"))
+	if err != nil {
+		return err
+	}
+	logger = l
+
+	// spans are exported when configured, joining the trace of the workflow
+	shutdown, err := setupTracing(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			logger.Error("Failed to export traces", "error", err)
+		}
+	}()
+
+	ctx, span := tracer().Start(contextFromEnv(ctx), "manual-approval", trace.WithAttributes(attribute.String("handler", k.Handler)))
+	defer span.End()
+	k.Context = ctx
+
+	err = k.runHandler()
+	if err != nil {
+		recordError(span, err)
+	}
+	return err
+}
+
+func (k *Config) runHandler() error {
 	switch k.Handler {
 	case "init":
 		return k.init()
@@ -61,7 +98,7 @@ func (k *Config) Run(ctx context.Context) error {
 }
 
 func (k *Config) defaultConfig() (string, string, error) {
-	debugf("Read default configuration from the environment variables\n")
+	logger.Debug("Read default configuration from the environment variables")
 
 	apiUrl := os.Getenv("URL")
 	if apiUrl == "" {
@@ -76,8 +113,32 @@ func (k *Config) defaultConfig() (string, string, error) {
 	return apiUrl, apiToken, nil
 }
 
+// Read an optional boolean environment variable, which is false by default
+func boolEnv(name string) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// Split a comma or newline separated list, dropping empty entries
+func parseList(value string) []string {
+	var list []string
+	for _, line := range strings.Split(value, "\n") {
+		for _, entry := range strings.Split(line, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				list = append(list, entry)
+			}
+		}
+	}
+	return list
+}
+
 func (k *Config) init() error {
-	debugf("Inside init handler\n")
+	logger.Debug("Inside init handler")
+	span, end := k.startSpan("init")
+	defer end()
 
 	// approvers are optional
 	approvers := os.Getenv("APPROVERS")
@@ -110,6 +171,132 @@ func (k *Config) init() error {
 
 	// get approvalInputs if configured for the manual approval job
 	inputs := os.Getenv("INPUTS")
+	if inputs != "" {
+		if _, err := parseInputs(inputs); err != nil {
+			return fmt.Errorf("invalid approvalInputs: %w", err)
+		}
+		// dynamic choice options are resolved once, when the approval is requested
+		inputs, err = k.resolveInputOptions(inputs)
+		if err != nil {
+			return fmt.Errorf("invalid approvalInputs: %w", err)
+		}
+	}
+
+	parsedApprovers, err := parseApprovers(approvers)
+	if err != nil {
+		return err
+	}
+
+	// approvers can also be derived from the owners of the changed paths
+	var ownerGroups []ownerGroup
+	if codeowners := os.Getenv("APPROVERS_FROM_CODEOWNERS"); codeowners != "" {
+		paths, err := changedPaths(os.Getenv("CHANGED_PATHS"), os.Getenv("CHANGED_PATHS_FILE"))
+		if err != nil {
+			return err
+		}
+		parsedApprovers, ownerGroups, err = k.approversFromCodeowners(parsedApprovers, codeowners, paths)
+		if err != nil {
+			return err
+		}
+	}
+	approverList := approverNames(parsedApprovers)
+	logger.Debug("Approvers", "approvers", approverList)
+	span.SetAttributes(
+		attribute.Int("approval.approvers.count", len(approverList)),
+		attribute.Int("approval.approver_groups.count", len(ownerGroups)),
+	)
+
+	// by default approvers are sent as-is, without resolving them first
+	approverValidation, err := parseApproverValidation(os.Getenv("VALIDATE_APPROVERS"))
+	if err != nil {
+		return err
+	}
+	err = k.validateApprovers(approverApiNames(parsedApprovers), approverValidation)
+	if err != nil {
+		k.Output.Printf("ERROR: %s\n", err)
+		ferr := k.writeStatus("FAILED", err.Error())
+		if ferr != nil {
+			return ferr
+		}
+		return err
+	}
+
+	// instructions can also be loaded from a workspace file or a URL
+	inlineInstructions := instructions != ""
+	instructions, err = k.loadInstructions(instructions, os.Getenv("INSTRUCTIONS_FILE"), os.Getenv("INSTRUCTIONS_URL"))
+	if err != nil {
+		return err
+	}
+
+	// by default instructions are written to the log as plain text
+	logFormat, err := parseLogFormat(os.Getenv("INSTRUCTIONS_LOG_FORMAT"))
+	if err != nil {
+		return err
+	}
+
+	// expand template variables in the inline instructions. Instructions loaded from a file or a URL
+	// are not a template, so that their authors cannot publish the environment of the job.
+	if inlineInstructions {
+		instructions, err = renderInstructionsTemplate(instructions, approverList, inputs, os.Getenv("INSTRUCTION_VARS"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// unchecked task list items in the instructions become inputs the approvers have to tick
+	var generatedInputs []inputDefinition
+	enforceChecklist, err := boolEnv("ENFORCE_CHECKLIST")
+	if err != nil {
+		return err
+	}
+	if enforceChecklist {
+		generatedInputs = append(generatedInputs, checklistInputs(checklistItems(instructions))...)
+	}
+
+	// approvers have to pick one of the reason codes for their decision
+	if reasonCodes := parseList(os.Getenv("REASON_CODES")); len(reasonCodes) > 0 {
+		generatedInputs = append(generatedInputs, reasonCodeInputDefinition(reasonCodes))
+	}
+
+	if len(generatedInputs) > 0 {
+		inputs, err = appendInputs(inputs, generatedInputs)
+		if err != nil {
+			return fmt.Errorf("failed to add generated inputs to approvalInputs: %w", err)
+		}
+	}
+
+	// an approval with the same reuse key within the window is reused instead of requesting a new one
+	reuseKey := strings.TrimSpace(os.Getenv("REUSE_KEY"))
+	reuseWindow, err := parseReuseWindow(os.Getenv("REUSE_APPROVAL_WITHIN"))
+	if err != nil {
+		return err
+	}
+	reuseSupported := true
+	if reuseWindow > 0 {
+		if reuseKey == "" {
+			return fmt.Errorf("reuseApprovalWithin requires a reuseKey")
+		}
+		decision, err := k.reusableDecision(reuseKey, reuseWindow)
+		if err != nil {
+			k.Output.Printf("WARNING: %s\n", err)
+			reuseSupported = !isNotSupported(err)
+		} else if decision != nil {
+			// the earlier decision has to comply with the approval policies of this request
+			if err := k.validateReusedDecision(decision, inputs, parsedApprovers, ownerGroups, disallowLaunchedByUser); err != nil {
+				k.Output.Printf("WARNING: The approval of the approval request %s is not reused: %s\n", decision.displayName(), err)
+			} else {
+				span.SetAttributes(attribute.String("approval.reused_from", decision.Id))
+				return k.reuseDecision(decision, reuseKey, &approvalState{
+					Version:            stateVersion,
+					Approvers:          approverList,
+					Inputs:             inputs,
+					Instructions:       instructions,
+					InstructionsSha256: instructionsSha256(instructions),
+					RequestedOn:        now().UTC(),
+				})
+			}
+		}
+	}
 
 	// Construct request body
 	body := map[string]interface{}{
@@ -117,12 +304,32 @@ func (k *Config) init() error {
 		"notifyEligibleUsers":  notify,
 	}
 
-	if approvers != "" {
-		body["approvers"] = strings.Split(approvers, ",")
+	if len(approverList) > 0 {
+		body["approvers"] = approverApiNames(parsedApprovers)
+	}
+	// the kinds of the approvers are only known for prefixed approvers
+	if kinds := approverKinds(parsedApprovers); len(kinds) > 0 {
+		body["approverKinds"] = kinds
 	}
 
+	// a platform which supports approver groups requires an approval of one of the owners of each group
+	var approverGroups [][]string
+	if len(ownerGroups) > 0 {
+		groups := make([][]string, len(ownerGroups))
+		approverGroups = make([][]string, len(ownerGroups))
+		for i, group := range ownerGroups {
+			groups[i] = approverApiNames(group.owners)
+			approverGroups[i] = approverNames(group.owners)
+		}
+		body["approverGroups"] = groups
+	}
+
+	// send both the markdown source and the sanitized html rendering
+	var instructionsHtml string
 	if instructions != "" {
+		instructionsHtml = sanitizeHtml(markdown(instructions))
 		body["instructions"] = instructions
+		body["instructionsHtml"] = instructionsHtml
 	}
 
 	if callbackToken != "" {
@@ -133,17 +340,37 @@ func (k *Config) init() error {
 		body["approvalInputs"] = inputs
 	}
 
+	// the decision can be reused by later approval requests with the same reuse key, if the platform supports it
+	if reuseKey != "" && reuseSupported {
+		body["reuseKey"] = reuseKey
+	}
+
+	// the older approval requests with the same concurrency key are looked up before the new one is created,
+	// so that the new one is never superseded
+	concurrencyKey := strings.TrimSpace(os.Getenv("CONCURRENCY_KEY"))
+	var pending []PendingApproval
+	if concurrencyKey != "" {
+		pending, err = k.pendingApprovals(concurrencyKey)
+		if err != nil {
+			k.Output.Printf("WARNING: %s\n", err)
+		}
+		// the key is only sent to a platform which supports it
+		if !isNotSupported(err) {
+			body["concurrencyKey"] = concurrencyKey
+		}
+	}
+
 	resp, err := k.post("/v1/workflows/approval", body)
 	if err != nil {
 		k.Output.Printf("ERROR: API call failed with error: '%s'\n", err)
 		k.Output.Printf("ERROR: API response: '%s'\n", resp)
-		ferr := writeStatus("FAILED", fmt.Sprintf("Failed to initialize workflow manual approval request: '%s'", err))
+		ferr := k.writeStatus("FAILED", fmt.Sprintf("Failed to initialize workflow manual approval request: '%s'", err))
 		if ferr != nil {
 			return ferr
 		}
 		return err
 	}
-	debugf("Response: '%s'\n", resp)
+	logger.Debug("Response", "response", resp)
 
 	//get the names of potential approvers from the response
 	parsedResp := CreateManualApprovalResponse{}
@@ -152,65 +379,168 @@ func (k *Config) init() error {
 		return err
 	}
 
+	span.SetAttributes(attribute.Int("approval.eligible_approvers.count", len(parsedResp.Approvers)))
+
 	users := make([]string, len(parsedResp.Approvers))
 	for i, approver := range parsedResp.Approvers {
 		users[i] = approver.UserName
 	}
 
+	// without confirmation the platform accepts a single approval of any of the owners
+	if len(ownerGroups) > 0 && len(parsedResp.ApproverGroups) == 0 {
+		k.Output.Printf("WARNING: The platform API did not confirm support for approver groups, the approval of any one of the owners is accepted\n")
+	}
+
 	k.Output.Printf("Waiting for approval from one of the following: %s\n", strings.Join(users, ","))
 	if instructions != "" {
-		k.Output.Printf("Instructions:\n%s\n", markdown(instructions))
+		k.Output.Printf("Instructions:\n%s\n", formatInstructionsForLog(instructions, instructionsHtml, logFormat))
+	}
+
+	// the callback and cancel handlers read the request from the approval state
+	approvalId := parsedResp.Id
+	if approvalId == "" {
+		approvalId, err = newApprovalId()
+		if err != nil {
+			return err
+		}
+	}
+
+	// only the latest approval request with the concurrency key waits for a decision
+	if len(pending) > 0 {
+		superseded := k.supersedeApprovals(pending, approvalId, parsedResp.Url)
+		span.SetAttributes(attribute.Int("approval.superseded.count", superseded))
 	}
 
-	return writeStatus("PENDING_APPROVAL", "Waiting for approval from approvers")
+	state := &approvalState{
+		Version:            stateVersion,
+		ApprovalId:         approvalId,
+		Approvers:          approverList,
+		Inputs:             inputs,
+		Instructions:       instructions,
+		InstructionsSha256: instructionsSha256(instructions),
+		RequestedOn:        now().UTC(),
+	}
+	if len(approverGroups) > 0 {
+		state.ApproverGroups = approverGroups
+	}
+	err = k.saveState(state)
+	if err != nil {
+		return err
+	}
+
+	return k.writeStatus("PENDING_APPROVAL", "Waiting for approval from approvers")
 }
 
 func (k *Config) callback() error {
-	debugf("Inside callback handler\n")
+	logger.Debug("Inside callback handler")
+	span, end := k.startSpan("callback")
+	defer end()
 
 	payload := os.Getenv("PAYLOAD")
 	if payload == "" {
 		return fmt.Errorf("PAYLOAD environment variable missing")
 	}
 
-	debugf("Incoming payload: '%s'\n", payload)
+	// the response cannot be validated without the approval state and the configuration, so it is rejected
+	rejectInvalidConfig := func(err error) error {
+		return k.failInvalidConfig(map[string]interface{}{
+			"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+			"comments": fmt.Sprintf("Automatically rejected as the approval response could not be validated: %s", err),
+		}, err)
+	}
+
+	state, err := k.loadState()
+	if err != nil {
+		return rejectInvalidConfig(err)
+	}
+
+	// by default no evidence is written
+	evidenceFormats, err := parseEvidenceFormats(os.Getenv("EVIDENCE_FORMAT"))
+	if err != nil {
+		return rejectInvalidConfig(err)
+	}
+
+	// the approval inputs sent by the init handler are used to validate and mask the input values,
+	// falling back to the declared approval inputs
+	inputs := os.Getenv("INPUTS")
+	if state != nil && state.Inputs != "" {
+		inputs = state.Inputs
+	}
+	inputDefs, err := parseInputs(inputs)
+	if err != nil {
+		return rejectInvalidConfig(fmt.Errorf("invalid approvalInputs: %w", err))
+	}
+	secrets := secretInputs(inputDefs)
 
 	parsedPayload := map[string]interface{}{}
-	err := json.Unmarshal([]byte(payload), &parsedPayload)
+	err = decodeJSON(payload, &parsedPayload)
 	if err != nil {
 		return err
 	}
 
+	// secret input values are masked in the logs
+	for name, value := range payloadInputValues(parsedPayload) {
+		if s, ok := value.(string); ok && secrets[name] {
+			redactValue(s)
+		}
+	}
+	logger.Debug("Incoming payload", "payload", parsedPayload)
+
 	approvalStatus := parsedPayload["status"].(string)
-	debugf("Approval status: '%s'\n", approvalStatus)
+	logger.Debug("Approval status", "status", approvalStatus)
+	span.SetAttributes(attribute.String("approval.status", approvalStatus))
 
 	comments := parsedPayload["comments"].(string)
-	debugf("Comments: '%s'\n", comments)
+	logger.Debug("Comments", "comments", comments)
 
 	respondedOn := parsedPayload["respondedOn"].(string)
-	debugf("Responded on: '%s'\n", respondedOn)
+	logger.Debug("Responded on", "respondedOn", respondedOn)
 
 	approverUserName := parsedPayload["userName"].(string)
-	debugf("Approver user name: '%s'\n", approverUserName)
+	logger.Debug("Approver user name", "userName", approverUserName)
+
+	// reject responses which violate the approval policies of the manual approval job
+	userId, _ := parsedPayload["userId"].(string)
+	email, _ := parsedPayload["email"].(string)
+	err = k.validateResponder(userId, approverUserName, email)
+	if err == nil {
+		err = validateResponse(approvalStatus, comments, inputDefs, payloadInputValues(parsedPayload))
+	}
+	if err != nil {
+		return k.rejectResponse(approverUserName, err)
+	}
+
+	// values of conditional inputs which are not shown are discarded
+	dropHiddenInputs(parsedPayload, inputDefs)
 
 	// POST request expects input param values to be strings, so converting values to string
 	// Also, creating a map with input values in original type to be made available in outputs
-	modifiedInputsParamForPost, outputsMap, err4 := formatInputsForPost(parsedPayload)
+	modifiedInputsParamForPost, outputsMap, err4 := formatInputsForPost(parsedPayload, secrets)
 	if err4 != nil {
 		return err4
 	}
 
+	// the input values have to fit the outputs before the decision is recorded
+	envFile, err := inputsEnvFile()
+	if err != nil {
+		return err
+	}
+	err = checkInputOutputSizes(outputsMap, os.Getenv("OUTPUT_PREFIX"), envFile)
+	if err != nil {
+		return k.rejectResponse(approverUserName, err)
+	}
+
 	resp, err := k.post("/v1/workflows/approval/status", parsedPayload)
 	if err != nil {
 		k.Output.Printf("ERROR: API call failed with error: '%s'\n", err)
 		k.Output.Printf("ERROR: API response: '%s'\n", resp)
-		ferr := writeStatus("FAILED", fmt.Sprintf("Failed to change workflow manual approval status: '%s'", err))
+		ferr := k.writeStatus("FAILED", fmt.Sprintf("Failed to change workflow manual approval status: '%s'", err))
 		if ferr != nil {
 			return ferr
 		}
 		return err
 	}
-	debugf("Response: '%s'\n", resp)
+	logger.Debug("Response", "response", resp)
 
 	jobStatus, err2 := k.processApprovalStatus(approvalStatus, approverUserName, respondedOn, comments)
 	if err2 != nil {
@@ -223,10 +553,93 @@ func (k *Config) callback() error {
 	//
 	err3 := k.writeToOutputs(outputsMap, comments)
 	if err3 != nil {
+		k.Output.Printf("ERROR: %s\n", err3)
+		ferr := k.writeStatus("FAILED", err3.Error())
+		if ferr != nil {
+			return ferr
+		}
 		return err3
 	}
 
-	return writeStatus(jobStatus, "Successfully changed workflow manual approval status")
+	// export the reason code picked by the approver
+	if reasonCode, ok := outputsMap[reasonCodeInput].(string); ok {
+		err = k.writeAsOutput("reasonCode", []byte(reasonCode))
+		if err != nil {
+			return err
+		}
+	}
+
+	decidedOn, err := time.Parse(time.RFC3339, respondedOn)
+	if err != nil {
+		logger.Debug("Response time is not in RFC 3339 format, using the current time", "respondedOn", respondedOn)
+		decidedOn = now()
+	}
+	outcome := strings.ToLower(jobStatus)
+	err = k.recordDecision(state, outcome, decidedOn)
+	if err != nil {
+		return err
+	}
+	err = k.saveDecision(state, outcome, decidedOn)
+	if err != nil {
+		return err
+	}
+
+	// report the decision on the run details page
+	summary := newApprovalSummary(state, outcome, decidedOn)
+	summary.Approver = approverUserName
+	summary.Inputs = summaryInputs(modifiedInputsParamForPost)
+	summary.Comments = comments
+	err = k.writeSummary(summary)
+	if err != nil {
+		return err
+	}
+
+	// compliance evidence of the decision
+	err = k.writeEvidence(evidenceFormats, state, outcome, decidedOn, parsedPayload)
+	if err != nil {
+		return err
+	}
+
+	return k.writeStatus(jobStatus, "Successfully changed workflow manual approval status")
+}
+
+// Close the approval request and fail the job, if the approval state or the configuration of the
+// handler is not valid
+func (k *Config) failInvalidConfig(body map[string]interface{}, err error) error {
+	k.Output.Printf("ERROR: %s\n", err)
+	resp, perr := k.post("/v1/workflows/approval/status", body)
+	if perr != nil {
+		k.Output.Printf("ERROR: API call failed with error: '%s'\n", perr)
+		k.Output.Printf("ERROR: API response: '%s'\n", resp)
+	} else {
+		logger.Debug("Response", "response", resp)
+	}
+	ferr := k.writeStatus("FAILED", err.Error())
+	if ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// Reject an approval response which is not accepted and fail the job. The approval request is
+// closed, as the approver cannot respond again.
+func (k *Config) rejectResponse(approverUserName string, err error) error {
+	k.Output.Printf("ERROR: Invalid approval response: %s\n", err)
+	resp, perr := k.post("/v1/workflows/approval/status", map[string]interface{}{
+		"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+		"comments": fmt.Sprintf("Automatically rejected as the approval response of %s was not accepted: %s", approverUserName, err),
+	})
+	if perr != nil {
+		k.Output.Printf("ERROR: API call failed with error: '%s'\n", perr)
+		k.Output.Printf("ERROR: API response: '%s'\n", resp)
+	} else {
+		logger.Debug("Response", "response", resp)
+	}
+	ferr := k.writeStatus("FAILED", fmt.Sprintf("Invalid approval response: %s", err))
+	if ferr != nil {
+		return ferr
+	}
+	return err
 }
 
 /*
@@ -234,7 +647,7 @@ func (k *Config) callback() error {
 * to string Also, creating a map with input values in original type to be made
 * available in outputs
  */
-func formatInputsForPost(parsedPayload map[string]interface{}) ([]interface{}, map[string]interface{}, error) {
+func formatInputsForPost(parsedPayload map[string]interface{}, secrets map[string]bool) ([]interface{}, map[string]interface{}, error) {
 	var modifiedInputsParamForPost []interface{}
 	outputsMap := make(map[string]interface{})
 
@@ -244,22 +657,24 @@ func formatInputsForPost(parsedPayload map[string]interface{}) ([]interface{}, m
 
 		for _, input := range modifiedInputsParamForPost {
 			ip := input.(map[string]interface{})
+			// Secret values never leave the callback handler
+			if secrets[ip["name"].(string)] {
+				ip["value"] = secretMask
+			}
 			// To print input param values in original type to outputs
 			outputsMap[ip["name"].(string)] = ip["value"]
 			// Converting param value to string type for POST request
-			inputVal := interfaceToString(ip["value"])
+			inputVal, err := formatValue(ip["value"])
+			if err != nil {
+				return nil, nil, fmt.Errorf("input '%s': %w", ip["name"], err)
+			}
 			ip["value"] = inputVal
 		}
 		parsedPayload["inputs"] = modifiedInputsParamForPost
 
-		updtVal, err2 := json.Marshal(parsedPayload)
-		if err2 != nil {
-			fmt.Println("Invalid payload after converting input values to string:", err2)
-			return nil, nil, err2
-		}
-		debugf("Inputs for post request: '%s'\n", updtVal)
+		logger.Debug("Inputs for post request", "inputs", modifiedInputsParamForPost)
 	} else {
-		debugf("**No Input Parameters Defined**\n")
+		logger.Debug("No input parameters defined")
 	}
 
 	return modifiedInputsParamForPost, outputsMap, nil
@@ -268,18 +683,29 @@ func formatInputsForPost(parsedPayload map[string]interface{}) ([]interface{}, m
 func (k *Config) writeToOutputs(outputsMap map[string]interface{}, comments string) error {
 
 	if outputsMap != nil {
+		// values exceeding the maximum output size are only available in the dotenv file of the state directory
+		envFile, err := inputsEnvFile()
+		if err != nil {
+			return err
+		}
+
 		outputBytes, err := json.Marshal(outputsMap)
 		if err != nil {
 			return err
 		}
-		err = writeAsOutput("approvalInputValues", outputBytes)
+		err = k.writeInputsOutput("approvalInputValues", outputBytes, envFile)
+		if err != nil {
+			return err
+		}
+		logger.Debug("Approval input values in outputs", "approvalInputValues", string(outputBytes))
+
+		err = k.writeInputOutputs(outputsMap, os.Getenv("OUTPUT_PREFIX"), envFile)
 		if err != nil {
 			return err
 		}
-		debugf("Approval Input Values in outputs: '%s'\n", string(outputBytes))
 	}
 
-	err := writeAsOutput("comments", []byte(comments))
+	err := k.writeAsOutput("comments", []byte(comments))
 	if err != nil {
 		return err
 	}
@@ -317,7 +743,7 @@ func (k *Config) processApprovalStatus(approvalStatus string, approverUserName s
 		k.Output.Printf("Rejected by %s on %s with comments:\n%s\n", approverUserName, respondedOn, comments)
 	default:
 		k.Output.Printf("ERROR: Unexpected approval status '%s'\n", approvalStatus)
-		ferr := writeStatus("FAILED", fmt.Sprintf("Unexpected approval status '%s'", approvalStatus))
+		ferr := k.writeStatus("FAILED", fmt.Sprintf("Unexpected approval status '%s'", approvalStatus))
 		if ferr != nil {
 			return "", ferr
 		}
@@ -326,54 +752,143 @@ func (k *Config) processApprovalStatus(approvalStatus string, approverUserName s
 	return jobStatus, nil
 }
 
-func interfaceToString(i interface{}) string {
-	switch v := i.(type) {
-	case string:
-		return v
-	case int:
-		return strconv.FormatInt(int64(v), 64)
-	case float64:
-		return strconv.FormatFloat(v, 'g', -1, 64)
-	case bool:
-		return strconv.FormatBool(v)
-	default:
-		return "unsupported type"
-	}
-}
-
 func (k *Config) cancel() error {
-	debugf("Inside cancel handler\n")
+	logger.Debug("Inside cancel handler")
+	span, end := k.startSpan("cancel")
+	defer end()
 
 	cancellationReason := os.Getenv("CANCELLATION_REASON")
 	if cancellationReason == "" {
 		return fmt.Errorf("CANCELLATION_REASON environment variable missing")
 	}
+	span.SetAttributes(attribute.String("approval.cancellation_reason", cancellationReason))
+
+	// the approval request is cancelled without onTimeout, if the approval state or the configuration is not valid
+	invalidConfig := map[string]interface{}{"status": cancellationOf(cancellationReason).apiStatus}
+
+	state, err := k.loadState()
+	if err != nil {
+		return k.failInvalidConfig(invalidConfig, err)
+	}
+
+	// by default no evidence is written
+	evidenceFormats, err := parseEvidenceFormats(os.Getenv("EVIDENCE_FORMAT"))
+	if err != nil {
+		return k.failInvalidConfig(invalidConfig, err)
+	}
+
+	// by default a timeout fails the job
+	onTimeout, err := parseOnTimeout(os.Getenv("ON_TIMEOUT"))
+	if err != nil {
+		return k.failInvalidConfig(invalidConfig, err)
+	}
+
+	c := cancellationOf(cancellationReason).onTimeout(onTimeout)
+
+	// an automatic decision has to comply with the approval policies like an approver's response,
+	// otherwise the approval request times out
+	var inputDefs []inputDefinition
+	if c.automatic {
+		inputs := os.Getenv("INPUTS")
+		if state != nil && state.Inputs != "" {
+			inputs = state.Inputs
+		}
+		inputDefs, err = parseInputs(inputs)
+		if err != nil {
+			return k.failInvalidConfig(invalidConfig, fmt.Errorf("invalid approvalInputs: %w", err))
+		}
+		values := payloadInputValues(map[string]interface{}{"inputs": defaultInputs(inputDefs)})
+		err = validateDefaultInputs(inputDefs, values)
+		if err == nil {
+			err = validateResponse(c.apiStatus, strings.TrimSpace(os.Getenv("ON_TIMEOUT_COMMENTS")), inputDefs, values)
+		}
+		if err != nil {
+			k.Output.Printf("WARNING: The approval request is not %s automatically: %s\n", c.outcome, err)
+			c = cancellationOf(cancellationReason)
+		}
+	}
 
 	// Construct request body
-	body := map[string]interface{}{}
-	if cancellationReason == "CANCELLED" {
-		k.Output.Println("Workflow aborted by user")
-		k.Output.Println("Cancelling the manual approval request")
-		body["status"] = "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED"
-	} else {
-		k.Output.Println("Workflow timed out")
-		k.Output.Println("Workflow approval response was not received within allotted time.")
-		body["status"] = "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT"
+	for _, message := range c.messages {
+		k.Output.Println(message)
+	}
+	if state != nil && len(state.Approvers) > 0 {
+		k.Output.Printf("Approval was requested on %s from: %s\n", state.RequestedOn.Format(time.RFC3339), strings.Join(state.Approvers, ","))
+	}
+	body := map[string]interface{}{
+		"status": c.apiStatus,
+	}
+	outcome := c.outcome
+
+	// an automatic decision is sent with the default input values, and comments which state that it was automatic
+	var inputsForPost []interface{}
+	var outputsMap map[string]interface{}
+	var comments string
+	if c.automatic {
+		body["inputs"] = defaultInputs(inputDefs)
+		inputsForPost, outputsMap, err = formatInputsForPost(body, secretInputs(inputDefs))
+		if err != nil {
+			return err
+		}
+		comments = automaticComments(c, os.Getenv("ON_TIMEOUT_COMMENTS"))
+		body["comments"] = comments
 	}
 
 	resp, err := k.post("/v1/workflows/approval/status", body)
 	if err != nil {
 		k.Output.Printf("ERROR: API call failed with error: '%s'\n", err)
 		k.Output.Printf("ERROR: API response: '%s'\n", resp)
+		ferr := k.writeStatus("FAILED", fmt.Sprintf("Failed to cancel workflow manual approval request: '%s'", err))
+		if ferr != nil {
+			return ferr
+		}
 		return err
 	}
-	debugf("Response: '%s'\n", resp)
+	logger.Debug("Response", "response", resp)
 
-	return nil
+	if c.automatic {
+		k.Output.Printf("Comments:\n%s\n", comments)
+		k.formatInputsValsAndWriteToLog(inputsForPost)
+		err = k.writeToOutputs(outputsMap, comments)
+		if err != nil {
+			return err
+		}
+	}
+
+	decidedOn := now()
+	err = k.recordDecision(state, outcome, decidedOn)
+	if err != nil {
+		return err
+	}
+	err = k.saveDecision(state, outcome, decidedOn)
+	if err != nil {
+		return err
+	}
+
+	// report the cancellation on the run details page
+	summary := newApprovalSummary(state, outcome, decidedOn)
+	summary.Inputs = summaryInputs(inputsForPost)
+	summary.Comments = comments
+	err = k.writeSummary(summary)
+	if err != nil {
+		return err
+	}
+
+	// compliance evidence of the cancellation
+	body["cancellationReason"] = cancellationReason
+	err = k.writeEvidence(evidenceFormats, state, outcome, decidedOn, body)
+	if err != nil {
+		return err
+	}
+
+	return k.writeStatus(c.jobStatus, c.statusMessage())
 }
 
 func (k *Config) post(apiPath string, requestBody map[string]interface{}) (string, error) {
-	debugf("Post http request to the platform API endpoint: '%s'\n", apiPath)
+	return k.request("POST", apiPath, requestBody)
+}
+
+func (k *Config) request(method string, apiPath string, requestBody map[string]interface{}) (string, error) {
 
 	// Read default configuration from the environment variables
 	apiUrl, apiToken, err := k.defaultConfig()
@@ -392,7 +907,12 @@ func (k *Config) post(apiPath string, requestBody map[string]interface{}) (strin
 	if err != nil {
 		return "", err
 	}
-	debugf("Payload: '%s'\n", string(body))
+	logger.Debug("Request to the platform API", "method", method, "url", requestURL, "body", requestBody)
+
+	// In dry-run mode the request is printed instead of sent
+	if k.DryRun {
+		return "{}", k.printRequest(method, requestURL, requestBody)
+	}
 
 	// Use default http client if it is not already provided in the configuration
 	if k.Client == nil {
@@ -400,7 +920,7 @@ func (k *Config) post(apiPath string, requestBody map[string]interface{}) (strin
 	}
 
 	apiReq, err := http.NewRequest(
-		"POST",
+		method,
 		requestURL,
 		bytes.NewReader(body),
 	)
@@ -412,7 +932,9 @@ func (k *Config) post(apiPath string, requestBody map[string]interface{}) (strin
 	apiReq.Header.Set("Content-Type", "application/json")
 	apiReq.Header.Set("Accept", "application/json")
 
+	apiReq, span := k.traceRequest(apiReq)
 	resp, err := k.Client.Do(apiReq)
+	endRequestSpan(span, resp, err)
 	if err != nil {
 		return "", err
 	}
@@ -426,17 +948,149 @@ func (k *Config) post(apiPath string, requestBody map[string]interface{}) (strin
 	response := string(responseBody)
 
 	if resp.StatusCode != 200 {
-		return response, fmt.Errorf("failed to send event: \nPOST %s\nHTTP/%d %s\n", requestURL, resp.StatusCode, resp.Status)
+		return response, &apiStatusError{
+			statusCode: resp.StatusCode,
+			message:    fmt.Sprintf("failed to send event: \n%s %s\nHTTP/%d %s\n", method, requestURL, resp.StatusCode, resp.Status),
+		}
 	}
 
 	return response, nil
 }
 
-func debugf(format string, a ...any) {
-	if debug {
-		t := time.Now()
-		fmt.Printf("%s - DEBUG: "+format, append([]any{t.Format(time.RFC3339)}, a...)...)
+// apiStatusError is the error of a request which the platform API answered with a status other than 200
+type apiStatusError struct {
+	statusCode int
+	message    string
+}
+
+func (e *apiStatusError) Error() string {
+	return e.message
+}
+
+// Check whether the platform API does not provide the endpoint of a failed request. The endpoints
+// beyond creating and updating an approval request are optional, and the features using them are
+// skipped or fail with a clear message when the platform does not provide them.
+func isNotSupported(err error) bool {
+	var statusErr *apiStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.statusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	default:
+		return false
+	}
+}
+
+// Print a request to the platform API with the tokens redacted
+func (k *Config) printRequest(method string, requestURL string, requestBody map[string]interface{}) error {
+	redacted := make(map[string]interface{}, len(requestBody))
+	for key, value := range requestBody {
+		redacted[key] = value
+	}
+	if _, ok := redacted["token"]; ok {
+		redacted["token"] = secretMask
+	}
+
+	body, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return err
+	}
+	k.Output.Printf("DRY RUN: %s %s\n", method, requestURL)
+	k.Output.Printf("DRY RUN: Authorization: Bearer %s\n", secretMask)
+	k.Output.Printf("DRY RUN: request body:\n%s\n", body)
+	return nil
+}
+
+// Download a document over http(s), failing if it is larger than maxSize bytes
+func (k *Config) download(rawUrl string, maxSize int64) ([]byte, error) {
+	logger.Debug("Download document", "url", rawUrl)
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme '%s', only http and https are allowed", parsedUrl.Scheme)
+	}
+
+	// Use default http client if it is not already provided in the configuration
+	if k.Client == nil {
+		k.Client = &RealHttpClient{}
+	}
+
+	req, err := http.NewRequest("GET", parsedUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, span := k.traceRequest(req)
+	resp, err := k.Client.Do(req)
+	endRequestSpan(span, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to download: \nGET %s\nHTTP/%d %s\n", rawUrl, resp.StatusCode, resp.Status)
+	}
+
+	return readLimited(resp.Body, rawUrl, maxSize)
+}
+
+// Read a file from the workspace, failing if it is larger than maxSize bytes
+func readWorkspaceFile(path string, maxSize int64) ([]byte, error) {
+	path, err := workspacePath(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("Read file", "path", path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return readLimited(f, path, maxSize)
+}
+
+// Resolve a path relative to the workspace, failing if it is outside of the workspace
+func workspacePath(path string) (string, error) {
+	workspace := os.Getenv("CLOUDBEES_WORKSPACE")
+	if workspace == "" {
+		return path, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workspace, path)
+	}
+	rel, err := filepath.Rel(workspace, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the workspace %s", path, workspace)
 	}
+	return path, nil
+}
+
+func readLimited(r io.Reader, name string, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s exceeds the maximum size of %d bytes", name, maxSize)
+	}
+	return data, nil
+}
+
+// Write an output of the job, or print it in dry-run mode
+func (k *Config) writeAsOutput(name string, value []byte) error {
+	if k.DryRun {
+		k.Output.Printf("DRY RUN: output '%s':\n%s\n", name, value)
+		return nil
+	}
+	return writeAsOutput(name, value)
 }
 
 func writeAsOutput(name string, value []byte) error {
@@ -453,6 +1107,15 @@ func writeAsOutput(name string, value []byte) error {
 	return nil
 }
 
+// Write the status of the job, or print it in dry-run mode
+func (k *Config) writeStatus(status string, message string) error {
+	if k.DryRun {
+		k.Output.Printf("DRY RUN: status %s: %s\n", status, message)
+		return nil
+	}
+	return writeStatus(status, message)
+}
+
 func writeStatus(status string, message string) error {
 	statusFile := os.Getenv("CLOUDBEES_STATUS")
 	if statusFile == "" {
@@ -477,9 +1140,8 @@ func writeStatus(status string, message string) error {
 // Add markdown format support to instructions
 func markdown(value string) string {
 	var buf bytes.Buffer
-	md := goldmark.New()
 	if err := md.Convert([]byte(value), &buf); err != nil {
-		fmt.Printf("Failed to convert markdown to html: %v\n", err)
+		logger.Error("Failed to convert markdown to html", "error", err)
 	} else {
 		value = buf.String()
 	}
@@ -2,13 +2,18 @@ package manual_approval
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -16,6 +21,7 @@ import (
 var (
 	instructionsInput  = "***instruction***\n`instruction2`\n# instruction3\n## instruction4\n### instruction5\n\n> Blockquotes can contain multiple paragraphs\n>\n> Add a > on the blank lines between the paragraps.\n\n- First item\n- Second Item\n- Third item \n  - Indented item\n  - Indented item\n- Fourth item"
 	instructionsOutput = "<p><em><strong>instruction</strong></em>\n<code>instruction2</code></p>\n<h1>instruction3</h1>\n<h2>instruction4</h2>\n<h3>instruction5</h3>\n<blockquote>\n<p>Blockquotes can contain multiple paragraphs</p>\n<p>Add a &gt; on the blank lines between the paragraps.</p>\n</blockquote>\n<ul>\n<li>First item</li>\n<li>Second Item</li>\n<li>Third item\n<ul>\n<li>Indented item</li>\n<li>Indented item</li>\n</ul>\n</li>\n<li>Fourth item</li>\n</ul>\n"
+	instructionsText   = "instruction\n`instruction2`\n\ninstruction3\n============\n\ninstruction4\n------------\n\n### instruction5\n\n> Blockquotes can contain multiple paragraphs\n>\n> Add a > on the blank lines between the paragraps.\n\n- First item\n- Second Item\n- Third item\n  - Indented item\n  - Indented item\n- Fourth item\n"
 	approvalInputs     = "in1:\\n  type: string\\n  required: true\\n  description: One of the required approver inputs\\nin2:\\n  type: number\\n  description: a numeric input\\nin3:\\n  type: choice\\n  options:\\n    - op1\\n    - op2"
 )
 
@@ -95,13 +101,14 @@ func Test_defaultConfig(t *testing.T) {
 
 func Test_init(t *testing.T) {
 	tests := []struct {
-		name         string
-		reqCheckFunc func(req map[string]interface{})
-		respGenFunc  func() (*http.Response, error)
-		env          map[string]string
-		client       *MockHttpClient
-		output       []string
-		err          string
+		name          string
+		reqCheckFunc  func(req map[string]interface{})
+		respGenFunc   func() (*http.Response, error)
+		env           map[string]string
+		client        *MockHttpClient
+		inputsInState string
+		output        []string
+		err           string
 	}{
 		{
 			name: "success",
@@ -110,6 +117,7 @@ func Test_init(t *testing.T) {
 				require.Equal(t, []interface{}{"123", "user@mail.com"}, req["approvers"])
 				require.NotNil(t, req["instructions"])
 				require.Equal(t, instructionsInput, req["instructions"].(string))
+				require.Equal(t, instructionsOutput, req["instructionsHtml"].(string))
 				require.Equal(t, false, req["disallowLaunchByUser"].(bool))
 				require.Equal(t, false, req["notifyEligibleUsers"].(bool))
 			},
@@ -121,18 +129,263 @@ func Test_init(t *testing.T) {
 				}, nil
 			},
 			env: map[string]string{
-				"URL":              "http://test.com",
-				"API_TOKEN":        "test",
-				"CLOUDBEES_STATUS": "/tmp/test-status-out",
-				"APPROVERS":        "123,user@mail.com",
-				"INSTRUCTIONS":     instructionsInput,
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"APPROVERS":         "123,user@mail.com",
+				"INSTRUCTIONS":      instructionsInput,
+			},
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+				"Instructions:\n" + instructionsText + "\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with html log format",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, instructionsInput, req["instructions"].(string))
+				require.Equal(t, instructionsOutput, req["instructionsHtml"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                     "http://test.com",
+				"API_TOKEN":               "test",
+				"CLOUDBEES_STATUS":        "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":       "/tmp/test-outputs",
+				"APPROVERS":               "123,user@mail.com",
+				"INSTRUCTIONS":            instructionsInput,
+				"INSTRUCTIONS_LOG_FORMAT": "html",
+			},
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+				"Instructions:\n" + instructionsOutput + "\n",
+			},
+			err: "",
+		},
+		{
+			name: "failure with invalid log format",
+			env: map[string]string{
+				"URL":                     "http://test.com",
+				"API_TOKEN":               "test",
+				"CLOUDBEES_STATUS":        "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":       "/tmp/test-outputs",
+				"INSTRUCTIONS":            instructionsInput,
+				"INSTRUCTIONS_LOG_FORMAT": "markdown",
+			},
+			output: nil,
+			err:    "unsupported instructions log format 'markdown', valid values are: text, ansi, html",
+		},
+		{
+			name: "success with checklist",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "- [ ] I have verified the rollback plan", req["instructions"].(string))
+				require.Equal(t, "in1:\n  type: string\nchecklist-1:\n  type: boolean\n  required: true\n  default: false\n  description: I have verified the rollback plan\n", req["approvalInputs"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"INSTRUCTIONS":      "- [ ] I have verified the rollback plan",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"INPUTS":            "in1:\n  type: string\n",
+				"ENFORCE_CHECKLIST": "true",
+			},
+			inputsInState: "in1:\n  type: string\nchecklist-1:\n  type: boolean\n  required: true\n  default: false\n  description: I have verified the rollback plan\n",
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+				"Instructions:\n- [ ] I have verified the rollback plan\n\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with reason codes",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "reasonCode:\n  type: choice\n  required: true\n  description: Reason for the decision\n  options:\n    - planned\n    - hotfix\n", req["approvalInputs"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"REASON_CODES":      "planned, hotfix",
+			},
+			inputsInState: "reasonCode:\n  type: choice\n  required: true\n  description: Reason for the decision\n  options:\n    - planned\n    - hotfix\n",
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with approversFromCodeowners",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, []interface{}{"123", "org/api-team", "api-lead@mail.com", "org/docs"}, req["approvers"])
+				require.Equal(t, []interface{}{
+					map[string]interface{}{"name": "org/api-team", "kind": "team"},
+					map[string]interface{}{"name": "org/docs", "kind": "team"},
+				}, req["approverKinds"])
+				require.Equal(t, []interface{}{[]interface{}{"org/api-team", "api-lead@mail.com"}, []interface{}{"org/docs"}}, req["approverGroups"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                       "http://test.com",
+				"API_TOKEN":                 "test",
+				"CLOUDBEES_STATUS":          "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":         "/tmp/test-outputs",
+				"CLOUDBEES_WORKSPACE":       "testdata",
+				"APPROVERS":                 "123",
+				"APPROVERS_FROM_CODEOWNERS": "CODEOWNERS",
+				"CHANGED_PATHS":             "services/api/main.go\ndocs/intro.md",
+			},
+			output: []string{
+				"Approval requested from each of the following owner groups:\n",
+				" team:org/api-team, api-lead@mail.com (1 changed paths)\n",
+				" team:org/docs (1 changed paths)\n",
+				"WARNING: The platform API did not confirm support for approver groups, the approval of any one of the owners is accepted\n",
+				"Waiting for approval from one of the following: testUserName\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with approversFromCodeowners confirmed by the platform",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, []interface{}{"123", "org/api-team", "api-lead@mail.com", "org/docs"}, req["approvers"])
+				require.Equal(t, []interface{}{
+					map[string]interface{}{"name": "org/api-team", "kind": "team"},
+					map[string]interface{}{"name": "org/docs", "kind": "team"},
+				}, req["approverKinds"])
+				require.Equal(t, []interface{}{[]interface{}{"org/api-team", "api-lead@mail.com"}, []interface{}{"org/docs"}}, req["approverGroups"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}],"approverGroups":[["org/api-team","api-lead@mail.com"],["org/docs"]]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                       "http://test.com",
+				"API_TOKEN":                 "test",
+				"CLOUDBEES_STATUS":          "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":         "/tmp/test-outputs",
+				"CLOUDBEES_WORKSPACE":       "testdata",
+				"APPROVERS":                 "123",
+				"APPROVERS_FROM_CODEOWNERS": "CODEOWNERS",
+				"CHANGED_PATHS":             "services/api/main.go\ndocs/intro.md",
+			},
+			output: []string{
+				"Approval requested from each of the following owner groups:\n",
+				" team:org/api-team, api-lead@mail.com (1 changed paths)\n",
+				" team:org/docs (1 changed paths)\n",
+				"Waiting for approval from one of the following: testUserName\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with users and teams of the same name",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, []interface{}{"alice@example.com", "ops"}, req["approvers"])
+				require.Equal(t, []interface{}{
+					map[string]interface{}{"name": "ops", "kind": "user"},
+					map[string]interface{}{"name": "ops", "kind": "team"},
+				}, req["approverKinds"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"APPROVERS":         "Alice@example.com,ops,team:ops,user:ops,alice@example.com,user:ops",
+			},
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with instructions file which is not a template",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "Deploy {{ .Env.API_TOKEN }} to {{ .Env.URL }}\n", req["instructions"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CLOUDBEES_WORKSPACE": "testdata",
+				"INSTRUCTIONS_FILE":   "instructions.md",
 			},
 			output: []string{
 				"Waiting for approval from one of the following: testUserName\n",
-				"Instructions:\n<p><em><strong>instruction</strong></em>\n<code>instruction2</code></p>\n<h1>instruction3</h1>\n<h2>instruction4</h2>\n<h3>instruction5</h3>\n<blockquote>\n<p>Blockquotes can contain multiple paragraphs</p>\n<p>Add a &gt; on the blank lines between the paragraps.</p>\n</blockquote>\n<ul>\n<li>First item</li>\n<li>Second Item</li>\n<li>Third item\n<ul>\n<li>Indented item</li>\n<li>Indented item</li>\n</ul>\n</li>\n<li>Fourth item</li>\n</ul>\n\n",
+				"Instructions:\nDeploy {{ .Env.API_TOKEN }} to {{ .Env.URL }}\n\n",
 			},
 			err: "",
 		},
+		{
+			name: "failure with invalid approvers",
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"APPROVERS":         "123,group:ops",
+			},
+			output: nil,
+			err:    "invalid approvers entry 2 'group:ops': unsupported prefix 'group:', valid prefixes are: team:, user:",
+		},
+		{
+			name: "failure with invalid inputs",
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"INPUTS":            "in1:\n  type: list\n",
+			},
+			output: nil,
+			err:    "invalid approvalInputs: input 'in1': unsupported type 'list', valid types are: string, number, boolean, choice, multichoice, date, datetime, secret",
+		},
 		{
 			name: "success with callback.token",
 			reqCheckFunc: func(req map[string]interface{}) {
@@ -152,16 +405,17 @@ func Test_init(t *testing.T) {
 				}, nil
 			},
 			env: map[string]string{
-				"URL":              "http://test.com",
-				"API_TOKEN":        "test",
-				"CLOUDBEES_STATUS": "/tmp/test-status-out",
-				"APPROVERS":        "123,user@mail.com",
-				"INSTRUCTIONS":     instructionsInput,
-				"CALLBACK_TOKEN":   "test-callback-token",
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"APPROVERS":         "123,user@mail.com",
+				"INSTRUCTIONS":      instructionsInput,
+				"CALLBACK_TOKEN":    "test-callback-token",
 			},
 			output: []string{
 				"Waiting for approval from one of the following: testUserName\n",
-				"Instructions:\n<p><em><strong>instruction</strong></em>\n<code>instruction2</code></p>\n<h1>instruction3</h1>\n<h2>instruction4</h2>\n<h3>instruction5</h3>\n<blockquote>\n<p>Blockquotes can contain multiple paragraphs</p>\n<p>Add a &gt; on the blank lines between the paragraps.</p>\n</blockquote>\n<ul>\n<li>First item</li>\n<li>Second Item</li>\n<li>Third item\n<ul>\n<li>Indented item</li>\n<li>Indented item</li>\n</ul>\n</li>\n<li>Fourth item</li>\n</ul>\n\n",
+				"Instructions:\n" + instructionsText + "\n",
 			},
 			err: "",
 		},
@@ -185,16 +439,70 @@ func Test_init(t *testing.T) {
 				}, nil
 			},
 			env: map[string]string{
-				"URL":              "http://test.com",
-				"API_TOKEN":        "test",
-				"CLOUDBEES_STATUS": "/tmp/test-status-out",
-				"APPROVERS":        "123,user@mail.com",
-				"INSTRUCTIONS":     instructionsInput,
-				"INPUTS":           approvalInputs,
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"APPROVERS":         "123,user@mail.com",
+				"INSTRUCTIONS":      instructionsInput,
+				"INPUTS":            approvalInputs,
+			},
+			inputsInState: approvalInputs,
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+				"Instructions:\n" + instructionsText + "\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with optionsFrom",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "target:\n  type: choice\n  options:\n    - staging\n    - production\n", req["approvalInputs"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CLOUDBEES_WORKSPACE": "testdata",
+				"INPUTS":              "target:\n  type: choice\n  optionsFrom: environments.json\n",
+			},
+			inputsInState: "target:\n  type: choice\n  options:\n    - staging\n    - production\n",
+			output: []string{
+				"Waiting for approval from one of the following: testUserName\n",
+			},
+			err: "",
+		},
+		{
+			name: "success with a condition on optionsFrom",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "env:\n  type: choice\n  options:\n    - staging\n    - production\ntarget:\n  type: string\n  when:\n    env: production\n", req["approvalInputs"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{"approvers":[{"userName": "testUserName", "userId": "123", "email": "user@mail.com"}]}`)),
+				}, nil
 			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CLOUDBEES_WORKSPACE": "testdata",
+				"INPUTS":              "env:\n  type: choice\n  optionsFrom: environments.json\ntarget:\n  type: string\n  when:\n    env: production\n",
+			},
+			inputsInState: "env:\n  type: choice\n  options:\n    - staging\n    - production\ntarget:\n  type: string\n  when:\n    env: production\n",
 			output: []string{
 				"Waiting for approval from one of the following: testUserName\n",
-				"Instructions:\n<p><em><strong>instruction</strong></em>\n<code>instruction2</code></p>\n<h1>instruction3</h1>\n<h2>instruction4</h2>\n<h3>instruction5</h3>\n<blockquote>\n<p>Blockquotes can contain multiple paragraphs</p>\n<p>Add a &gt; on the blank lines between the paragraps.</p>\n</blockquote>\n<ul>\n<li>First item</li>\n<li>Second Item</li>\n<li>Third item\n<ul>\n<li>Indented item</li>\n<li>Indented item</li>\n</ul>\n</li>\n<li>Fourth item</li>\n</ul>\n\n",
 			},
 			err: "",
 		},
@@ -219,13 +527,14 @@ func Test_init(t *testing.T) {
 				"URL":                       "http://test.com",
 				"API_TOKEN":                 "test",
 				"CLOUDBEES_STATUS":          "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":         "/tmp/test-outputs",
 				"APPROVERS":                 "123,user@mail.com",
 				"INSTRUCTIONS":              instructionsInput,
 				"DISALLOW_LAUNCHED_BY_USER": "true",
 			},
 			output: []string{
 				"Waiting for approval from one of the following: testUserName\n",
-				"Instructions:\n<p><em><strong>instruction</strong></em>\n<code>instruction2</code></p>\n<h1>instruction3</h1>\n<h2>instruction4</h2>\n<h3>instruction5</h3>\n<blockquote>\n<p>Blockquotes can contain multiple paragraphs</p>\n<p>Add a &gt; on the blank lines between the paragraps.</p>\n</blockquote>\n<ul>\n<li>First item</li>\n<li>Second Item</li>\n<li>Third item\n<ul>\n<li>Indented item</li>\n<li>Indented item</li>\n</ul>\n</li>\n<li>Fourth item</li>\n</ul>\n\n",
+				"Instructions:\n" + instructionsText + "\n",
 			},
 			err: "",
 		},
@@ -250,6 +559,7 @@ func Test_init(t *testing.T) {
 				"URL":                       "http://test.com",
 				"API_TOKEN":                 "test",
 				"CLOUDBEES_STATUS":          "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":         "/tmp/test-outputs",
 				"APPROVERS":                 "123,user@mail.com",
 				"INSTRUCTIONS":              instructionsInput,
 				"DISALLOW_LAUNCHED_BY_USER": "invalid boolean",
@@ -278,13 +588,14 @@ func Test_init(t *testing.T) {
 				"URL":                       "http://test.com",
 				"API_TOKEN":                 "test",
 				"CLOUDBEES_STATUS":          "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":         "/tmp/test-outputs",
 				"APPROVERS":                 "123,user@mail.com",
 				"INSTRUCTIONS":              instructionsInput,
 				"NOTIFY_ALL_ELIGIBLE_USERS": "true",
 			},
 			output: []string{
 				"Waiting for approval from one of the following: testUserName\n",
-				"Instructions:\n<p><em><strong>instruction</strong></em>\n<code>instruction2</code></p>\n<h1>instruction3</h1>\n<h2>instruction4</h2>\n<h3>instruction5</h3>\n<blockquote>\n<p>Blockquotes can contain multiple paragraphs</p>\n<p>Add a &gt; on the blank lines between the paragraps.</p>\n</blockquote>\n<ul>\n<li>First item</li>\n<li>Second Item</li>\n<li>Third item\n<ul>\n<li>Indented item</li>\n<li>Indented item</li>\n</ul>\n</li>\n<li>Fourth item</li>\n</ul>\n\n",
+				"Instructions:\n" + instructionsText + "\n",
 			},
 			err: "",
 		},
@@ -309,6 +620,7 @@ func Test_init(t *testing.T) {
 				"URL":                       "http://test.com",
 				"API_TOKEN":                 "test",
 				"CLOUDBEES_STATUS":          "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":         "/tmp/test-outputs",
 				"APPROVERS":                 "123,user@mail.com",
 				"INSTRUCTIONS":              instructionsInput,
 				"NOTIFY_ALL_ELIGIBLE_USERS": "invalid boolean",
@@ -334,11 +646,12 @@ func Test_init(t *testing.T) {
 				}, nil
 			},
 			env: map[string]string{
-				"URL":              "http://test.com",
-				"API_TOKEN":        "test",
-				"CLOUDBEES_STATUS": "/tmp/test-status-out",
-				"APPROVERS":        "123,user@mail.com",
-				"INSTRUCTIONS":     instructionsInput,
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"APPROVERS":         "123,user@mail.com",
+				"INSTRUCTIONS":      instructionsInput,
 			},
 			output: []string{
 				"ERROR: API call failed with error: 'failed to send event: \nPOST http://test.com/v1/workflows/approval\nHTTP/500 500 Internal Server Error\n'\n",
@@ -356,6 +669,13 @@ func Test_init(t *testing.T) {
 					os.Unsetenv(k)
 				}(k)
 			}
+			outputs_dir, exists := tt.env["CLOUDBEES_OUTPUTS"]
+			if exists {
+				os.Mkdir(outputs_dir, 0755)
+				defer func(dir string) {
+					os.RemoveAll(dir)
+				}(outputs_dir)
+			}
 
 			var testOutput []string
 
@@ -404,6 +724,13 @@ func Test_init(t *testing.T) {
 				out, ferr := os.ReadFile(tt.env["CLOUDBEES_STATUS"])
 				require.NoError(t, ferr)
 				require.Equal(t, "{\"message\":\"Waiting for approval from approvers\",\"status\":\"PENDING_APPROVAL\"}", string(out))
+				out, ferr = os.ReadFile(filepath.Join(tt.env["CLOUDBEES_OUTPUTS"], "approvalState"))
+				require.NoError(t, ferr)
+				state := approvalState{}
+				require.NoError(t, json.Unmarshal(out, &state))
+				require.NotEmpty(t, state.ApprovalId)
+				require.False(t, state.RequestedOn.IsZero())
+				require.Equal(t, tt.inputsInState, state.Inputs)
 			} else {
 				require.Error(t, err)
 				require.Equal(t, tt.err, err.Error())
@@ -424,6 +751,8 @@ func Test_callback(t *testing.T) {
 		statusInFile      string
 		commentsInOutput  string
 		inputValsInOutput string
+		reasonCodeOutput  string
+		outputFiles       map[string]string
 		output            []string
 		err               string
 	}{
@@ -526,13 +855,10 @@ func Test_callback(t *testing.T) {
 			err: "",
 		},
 		{
-			name: "failure UNSPECIFIED",
+			name: "failure APPROVED - checklist not acknowledged",
 			reqCheckFunc: func(req map[string]interface{}) {
-				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED", req["status"].(string))
-				require.Equal(t, "test comments", req["comments"].(string))
-				require.Equal(t, "123", req["userId"].(string))
-				require.Equal(t, "testUserName", req["userName"].(string))
-				require.Equal(t, "2009-11-10T23:00:00Z", req["respondedOn"].(string))
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
 			},
 			respGenFunc: func() (*http.Response, error) {
 				return &http.Response{
@@ -542,149 +868,1019 @@ func Test_callback(t *testing.T) {
 				}, nil
 			},
 			env: map[string]string{
-				"URL":              "http://test.com",
-				"API_TOKEN":        "test",
-				"CLOUDBEES_STATUS": "/tmp/test-status-out",
-				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED\",\"comments\":\"test comments\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":null}",
-			},
-			statusInFile: "{\"message\":\"Unexpected approval status 'UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED'\",\"status\":\"FAILED\"}",
-			output: []string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"ENFORCE_CHECKLIST": "true",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"checklist-1\",\"value\":true},{\"name\":\"checklist-2\",\"value\":false,\"is_default\":true}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: checklist item 'checklist-2' must be acknowledged before approving\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: checklist item 'checklist-2' must be acknowledged before approving\n",
+			},
+			err: "checklist item 'checklist-2' must be acknowledged before approving",
+		},
+		{
+			name: "failure APPROVED - checklist item missing from the response",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"ENFORCE_CHECKLIST": "true",
+				"APPROVAL_STATE":    `{"version":1,"approvalId":"1234","inputs":"checklist-1:\n  type: boolean\n  required: true\n  default: false\n  description: Rollback plan verified\nchecklist-2:\n  type: boolean\n  required: true\n  default: false\n  description: Release notes written\n","requestedOn":"2009-11-10T22:00:00Z"}`,
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"checklist-1\",\"value\":true}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: checklist item 'checklist-2' must be acknowledged before approving\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: checklist item 'checklist-2' must be acknowledged before approving\n",
+			},
+			err: "checklist item 'checklist-2' must be acknowledged before approving",
+		},
+		{
+			name: "success REJECTED - checklist not acknowledged",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"ENFORCE_CHECKLIST": "true",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_REJECTED\",\"comments\":\"test comments2\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"checklist-1\",\"value\":false,\"is_default\":true}]}",
+			},
+			statusInFile:      "{\"message\":\"Successfully changed workflow manual approval status\",\"status\":\"REJECTED\"}",
+			commentsInOutput:  "test comments2",
+			inputValsInOutput: "{\"checklist-1\":false}",
+			output: []string{
+				"Rejected by testUserName on 2009-11-10T23:00:00Z with comments:\ntest comments2\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" checklist-1: false (default) \n",
+			},
+			err: "",
+		},
+		{
+			name: "success REJECTED - reason code",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"REQUIRE_COMMENT":   "rejected",
+				"REASON_CODES":      "planned,risk",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_REJECTED\",\"comments\":\"too risky\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"reasonCode\",\"value\":\"risk\"}]}",
+			},
+			statusInFile:      "{\"message\":\"Successfully changed workflow manual approval status\",\"status\":\"REJECTED\"}",
+			commentsInOutput:  "too risky",
+			inputValsInOutput: "{\"reasonCode\":\"risk\"}",
+			reasonCodeOutput:  "risk",
+			output: []string{
+				"Rejected by testUserName on 2009-11-10T23:00:00Z with comments:\ntoo risky\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" reasonCode: risk \n",
+			},
+			err: "",
+		},
+		{
+			name: "failure APPROVED - invalid evidence format",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{
+					"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+					"comments": "Automatically rejected as the approval response could not be validated: unsupported evidence format 'sarif', valid values are: junit, json",
+				}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"EVIDENCE_FORMAT":  "sarif",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+			},
+			statusInFile: "{\"message\":\"unsupported evidence format 'sarif', valid values are: junit, json\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: unsupported evidence format 'sarif', valid values are: junit, json\n",
+			},
+			err: "unsupported evidence format 'sarif', valid values are: junit, json",
+		},
+		{
+			name: "failure APPROVED - invalid approval state",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{
+					"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+					"comments": "Automatically rejected as the approval response could not be validated: failed to load the approval state: unexpected end of JSON input",
+				}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"APPROVAL_STATE":   "{\"version\":1,",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+			},
+			statusInFile: "{\"message\":\"failed to load the approval state: unexpected end of JSON input\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: failed to load the approval state: unexpected end of JSON input\n",
+			},
+			err: "failed to load the approval state: unexpected end of JSON input",
+		},
+		{
+			name: "failure REJECTED - comment required",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"REQUIRE_COMMENT":  "rejected",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_REJECTED\",\"comments\":\"\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: a comment is required when the request is rejected\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: a comment is required when the request is rejected\n",
+			},
+			err: "a comment is required when the request is rejected",
+		},
+		{
+			name: "failure APPROVED - input value exceeds the maximum output size",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{
+					"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+					"comments": "Automatically rejected as the approval response of testUserName was not accepted: output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file",
+				}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"INPUTS":           "notes:\n  type: string\n",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"notes\",\"value\":\"" + strings.Repeat("x", maxOutputSize) + "\"}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file\n",
+			},
+			err: "output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file",
+		},
+		{
+			name: "failure REJECTED - comment required, rejection not posted",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{
+					"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+					"comments": "Automatically rejected as the approval response of testUserName was not accepted: a comment is required when the request is rejected",
+				}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 500,
+					Status:     "500 Internal Server Error",
+					Body:       io.NopCloser(bytes.NewBufferString(`wrong parameter`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"REQUIRE_COMMENT":  "rejected",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_REJECTED\",\"comments\":\"\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: a comment is required when the request is rejected\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: a comment is required when the request is rejected\n",
+				"ERROR: API call failed with error: 'failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n'\n",
+				"ERROR: API response: 'wrong parameter'\n",
+			},
+			err: "a comment is required when the request is rejected",
+		},
+		{
+			name: "success APPROVED - extended input types",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", req["status"].(string))
+				require.Equal(t, []interface{}{
+					map[string]interface{}{"name": "regions", "value": "[\"eu\",\"us\"]"},
+					map[string]interface{}{"name": "window", "value": "2024-02-29"},
+					map[string]interface{}{"name": "otp", "value": "********"},
+				}, req["inputs"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"INPUTS":            "regions:\n  type: multichoice\n  options: [eu, us, ap]\nwindow:\n  type: date\notp:\n  type: secret\n  pattern: ^[0-9]{6}$\n",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"regions\",\"value\":[\"eu\",\"us\"]},{\"name\":\"window\",\"value\":\"2024-02-29\"},{\"name\":\"otp\",\"value\":\"123456\"}]}",
+			},
+			statusInFile:      "{\"message\":\"Successfully changed workflow manual approval status\",\"status\":\"APPROVED\"}",
+			commentsInOutput:  "test comments1",
+			inputValsInOutput: "{\"otp\":\"********\",\"regions\":[\"eu\",\"us\"],\"window\":\"2024-02-29\"}",
+			outputFiles: map[string]string{
+				"regions":           "[\"eu\",\"us\"]",
+				"window":            "2024-02-29",
+				"otp":               "********",
+				"approvalInputsEnv": "otp='********'\nregions='[\"eu\",\"us\"]'\nwindow='2024-02-29'\n",
+			},
+			output: []string{
+				"Approved by testUserName on 2009-11-10T23:00:00Z with comments:\ntest comments1\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" regions: [\"eu\",\"us\"] \n",
+				" window: 2024-02-29 \n",
+				" otp: ******** \n",
+			},
+			err: "",
+		},
+		{
+			name: "success APPROVED - exact numbers and nested values",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, []interface{}{
+					map[string]interface{}{"name": "build", "value": "9007199254740993"},
+					map[string]interface{}{"name": "ratio", "value": "0.10"},
+					map[string]interface{}{"name": "meta", "value": "{\"a\":[1,2],\"b\":null}"},
+					map[string]interface{}{"name": "empty", "value": ""},
+				}, req["inputs"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"build\",\"value\":9007199254740993},{\"name\":\"ratio\",\"value\":0.10},{\"name\":\"meta\",\"value\":{\"b\":null,\"a\":[1,2]}},{\"name\":\"empty\",\"value\":null}]}",
+			},
+			statusInFile:      "{\"message\":\"Successfully changed workflow manual approval status\",\"status\":\"APPROVED\"}",
+			commentsInOutput:  "test comments1",
+			inputValsInOutput: "{\"build\":9007199254740993,\"empty\":null,\"meta\":{\"a\":[1,2],\"b\":null},\"ratio\":0.10}",
+			output: []string{
+				"Approved by testUserName on 2009-11-10T23:00:00Z with comments:\ntest comments1\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" build: 9007199254740993 \n",
+				" ratio: 0.10 \n",
+				" meta: {\"a\":[1,2],\"b\":null} \n",
+				" empty:  \n",
+			},
+			err: "",
+		},
+		{
+			name: "success APPROVED - per-input outputs with prefix",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"OUTPUT_PREFIX":     "approval-",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"rollback-target\",\"value\":\"v1.2.3\"},{\"name\":\"note\",\"value\":\"it's done\"}]}",
+			},
+			statusInFile:      "{\"message\":\"Successfully changed workflow manual approval status\",\"status\":\"APPROVED\"}",
+			commentsInOutput:  "test comments1",
+			inputValsInOutput: "{\"note\":\"it's done\",\"rollback-target\":\"v1.2.3\"}",
+			outputFiles: map[string]string{
+				"approval_rollback_target": "v1.2.3",
+				"approval_note":            "it's done",
+				"approvalInputsEnv":        "approval_note='it'\\''s done'\napproval_rollback_target='v1.2.3'\n",
+			},
+			output: []string{
+				"Approved by testUserName on 2009-11-10T23:00:00Z with comments:\ntest comments1\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" rollback-target: v1.2.3 \n",
+				" note: it's done \n",
+			},
+			err: "",
+		},
+		{
+			name: "success APPROVED - conditional input not shown",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, []interface{}{map[string]interface{}{"name": "action", "value": "deploy"}}, req["inputs"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":               "http://test.com",
+				"API_TOKEN":         "test",
+				"CLOUDBEES_STATUS":  "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS": "/tmp/test-outputs",
+				"INPUTS":            "action:\n  type: choice\n  options: [deploy, rollback]\nrollback-target:\n  type: string\n  required: true\n  when:\n    action: rollback\n",
+				"PAYLOAD":           "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"action\",\"value\":\"deploy\"},{\"name\":\"rollback-target\",\"value\":\"v1.2.3\"}]}",
+			},
+			statusInFile:      "{\"message\":\"Successfully changed workflow manual approval status\",\"status\":\"APPROVED\"}",
+			commentsInOutput:  "test comments1",
+			inputValsInOutput: "{\"action\":\"deploy\"}",
+			output: []string{
+				"Approved by testUserName on 2009-11-10T23:00:00Z with comments:\ntest comments1\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" action: deploy \n",
+			},
+			err: "",
+		},
+		{
+			name: "failure APPROVED - conditional input required",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"INPUTS":           "action:\n  type: choice\n  options: [deploy, rollback]\nrollback-target:\n  type: string\n  required: true\n  when:\n    action: rollback\n",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"action\",\"value\":\"rollback\"},{\"name\":\"rollback-target\",\"value\":\"\"}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: input 'rollback-target' is required when 'action' is 'rollback'\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: input 'rollback-target' is required when 'action' is 'rollback'\n",
+			},
+			err: "input 'rollback-target' is required when 'action' is 'rollback'",
+		},
+		{
+			name: "failure APPROVED - disallowed user",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{
+					"status":   "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+					"comments": "Automatically rejected as the approval response of testUserName was not accepted: user 'testUserName' (user@mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry '$COMMIT_AUTHOR'",
+				}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"DISALLOW_USERS":      "$COMMIT_AUTHOR",
+				"DISALLOW_USERS_VARS": "{\"COMMIT_AUTHOR\":\"user@mail.com\"}",
+				"PAYLOAD":             "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"email\":\"user@mail.com\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: user 'testUserName' (user@mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry '$COMMIT_AUTHOR'\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: user 'testUserName' (user@mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry '$COMMIT_AUTHOR'\n",
+			},
+			err: "user 'testUserName' (user@mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry '$COMMIT_AUTHOR'",
+		},
+		{
+			name: "failure APPROVED - invalid input value",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"INPUTS":           "window:\n  type: datetime\n",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"window\",\"value\":\"tomorrow\"}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: input 'window': expected an RFC 3339 date and time\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: input 'window': expected an RFC 3339 date and time\n",
+			},
+			err: "input 'window': expected an RFC 3339 date and time",
+		},
+		{
+			name: "failure APPROVED - value outside of the resolved options",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"INPUTS":           "target:\n  type: choice\n  optionsFrom: environments.json\n",
+				"APPROVAL_STATE":   "{\"version\":1,\"approvalId\":\"1234\",\"inputs\":\"target:\\n  type: choice\\n  options:\\n    - staging\\n    - production\\n\",\"requestedOn\":\"2009-11-10T22:00:00Z\"}",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"target\",\"value\":\"qa\"}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: input 'target': value must be one of: staging, production\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: input 'target': value must be one of: staging, production\n",
+			},
+			err: "input 'target': value must be one of: staging, production",
+		},
+		{
+			name: "failure APPROVED - options of optionsFrom not available",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Contains(t, req["comments"], "Automatically rejected as the approval response of testUserName was not accepted: ")
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"INPUTS":           "target:\n  type: choice\n  optionsFrom: environments.json\n",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments1\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":[{\"name\":\"target\",\"value\":\"qa\"}]}",
+			},
+			statusInFile: "{\"message\":\"Invalid approval response: input 'target': the options resolved from optionsFrom are not available, the approval state is missing\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: Invalid approval response: input 'target': the options resolved from optionsFrom are not available, the approval state is missing\n",
+			},
+			err: "input 'target': the options resolved from optionsFrom are not available, the approval state is missing",
+		},
+		{
+			name: "failure UNSPECIFIED",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED", req["status"].(string))
+				require.Equal(t, "test comments", req["comments"].(string))
+				require.Equal(t, "123", req["userId"].(string))
+				require.Equal(t, "testUserName", req["userName"].(string))
+				require.Equal(t, "2009-11-10T23:00:00Z", req["respondedOn"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED\",\"comments\":\"test comments\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\",\"inputs\":null}",
+			},
+			statusInFile: "{\"message\":\"Unexpected approval status 'UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED'\",\"status\":\"FAILED\"}",
+			output: []string{
 				"ERROR: Unexpected approval status 'UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED'\n",
 			},
-			err: "Unexpected approval status 'UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED'",
+			err: "Unexpected approval status 'UPDATE_MANUAL_APPROVAL_STATUS_UNSPECIFIED'",
+		},
+		{
+			name: "failure",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", req["status"].(string))
+				require.Equal(t, "test comments", req["comments"].(string))
+				require.Equal(t, "123", req["userId"].(string))
+				require.Equal(t, "testUserName", req["userName"].(string))
+				require.Equal(t, "2009-11-10T23:00:00Z", req["respondedOn"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 500,
+					Status:     "500 Internal Server Error",
+					Body:       io.NopCloser(bytes.NewBufferString(`wrong parameter`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":              "http://test.com",
+				"API_TOKEN":        "test",
+				"CLOUDBEES_STATUS": "/tmp/test-status-out",
+				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+			},
+			statusInFile: "{\"message\":\"Failed to change workflow manual approval status: 'failed to send event: \\nPOST http://test.com/v1/workflows/approval/status\\nHTTP/500 500 Internal Server Error\\n'\",\"status\":\"FAILED\"}",
+			output: []string{
+				"ERROR: API call failed with error: 'failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n'\n",
+				"ERROR: API response: 'wrong parameter'\n",
+			},
+			err: "failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+			outputs_dir, exists := tt.env["CLOUDBEES_OUTPUTS"]
+			if exists {
+				os.Mkdir(outputs_dir, 0755)
+				defer func(dir string) {
+					os.RemoveAll(dir)
+				}(outputs_dir)
+			}
+
+			var testOutput []string
+
+			// Run
+			c := Config{
+				Client: &MockHttpClient{
+					MockDo: func(req *http.Request) (*http.Response, error) {
+						require.NotNil(t, req)
+						require.Equal(t, "POST", req.Method)
+						require.Equal(t, "http://test.com/v1/workflows/approval/status", req.URL.String())
+						require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+						require.Equal(t, "application/json", req.Header.Get("Accept"))
+						require.Contains(t, req.Header.Get("Authorization"), "Bearer ")
+
+						reqBody := make(map[string]interface{})
+						bodyReader, err := req.GetBody()
+						require.NoError(t, err)
+						body, err := io.ReadAll(bodyReader)
+						require.NoError(t, err)
+						err = json.Unmarshal(body, &reqBody)
+						require.NoError(t, err)
+
+						// Check parsed request body
+						tt.reqCheckFunc(reqBody)
+
+						// Generate response
+						return tt.respGenFunc()
+					},
+				},
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+						fmt.Printf(format, a...)
+					},
+					MockPrintln: func(a ...any) {
+						testOutput = append(testOutput, fmt.Sprintln(a...))
+						fmt.Println(a...)
+					},
+				},
+			}
+			err := c.callback()
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+
+			if tt.inputValsInOutput != "" {
+				out, ferr := os.ReadFile(tt.env["CLOUDBEES_OUTPUTS"] + "/approvalInputValues")
+				require.NoError(t, ferr)
+				require.Equal(t, tt.inputValsInOutput, string(out))
+			}
+
+			if tt.commentsInOutput != "" {
+				out, ferr := os.ReadFile(tt.env["CLOUDBEES_OUTPUTS"] + "/comments")
+				require.NoError(t, ferr)
+				require.Equal(t, tt.commentsInOutput, string(out))
+			}
+
+			for name, value := range tt.outputFiles {
+				out, ferr := os.ReadFile(tt.env["CLOUDBEES_OUTPUTS"] + "/" + name)
+				require.NoError(t, ferr)
+				require.Equal(t, value, string(out))
+			}
+
+			if tt.reasonCodeOutput != "" {
+				out, ferr := os.ReadFile(tt.env["CLOUDBEES_OUTPUTS"] + "/reasonCode")
+				require.NoError(t, ferr)
+				require.Equal(t, tt.reasonCodeOutput, string(out))
+			}
+
+			out, ferr := os.ReadFile(tt.env["CLOUDBEES_STATUS"])
+			require.NoError(t, ferr)
+			require.Equal(t, tt.statusInFile, string(out))
+
+			require.True(t, slices.Equal(tt.output, testOutput))
+		})
+	}
+}
+
+func Test_cancel(t *testing.T) {
+	tests := []struct {
+		name         string
+		reqCheckFunc func(req map[string]interface{})
+		respGenFunc  func() (*http.Response, error)
+		env          map[string]string
+		client       *MockHttpClient
+		output       []string
+		statusInFile string
+		summary      string
+		comments     string
+		inputValues  string
+		err          string
+	}{
+		{
+			name: "success CANCELLED",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.NotNil(t, req["status"])
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "CANCELLED",
+			},
+			output: []string{
+				"Workflow aborted by user\n",
+				"Cancelling the manual approval request\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request aborted\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Aborted\n\n| | |\n|---|---|\n| Decision | Aborted |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "success TIMED_OUT",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.NotNil(t, req["status"])
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "TIMED_OUT",
+				"APPROVAL_STATE":      "{\"version\":1,\"approvalId\":\"1234\",\"approvers\":[\"123\"],\"instructions\":\"Check the **release notes**\",\"requestedOn\":\"2009-11-07T23:30:00Z\"}",
+			},
+			output: []string{
+				"Workflow timed out\n",
+				"Workflow approval response was not received within allotted time.\n",
+				"Approval was requested on 2009-11-07T23:30:00Z from: 123\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request timed out\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Timed out\n\n| | |\n|---|---|\n| Decision | Timed out |\n| Requested on | 2009-11-07T23:30:00Z |\n| Decided on | 2009-11-10T23:30:00Z |\n| Time waited | 72h0m0s |\n\n### Instructions\n\nCheck the **release notes**\n",
+			err:          "",
+		},
+		{
+			name: "success SUPERSEDED",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.NotNil(t, req["status"])
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "SUPERSEDED",
+			},
+			output: []string{
+				"Workflow approval request superseded by a newer request\n",
+				"Cancelling the manual approval request\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request superseded by a newer request\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Superseded\n\n| | |\n|---|---|\n| Decision | Superseded |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "success POLICY_DENIED",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.NotNil(t, req["status"])
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"].(string))
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "policy_denied",
+			},
+			output: []string{
+				"Workflow approval request denied by policy\n",
+				"Cancelling the manual approval request\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request denied by policy\",\"status\":\"REJECTED\"}",
+			summary:      "## Manual approval: Denied by policy\n\n| | |\n|---|---|\n| Decision | Denied by policy |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "TIMED_OUT approved automatically",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", req["status"])
+				require.Equal(t, "Automatically approved as the approval response was not received within allotted time.", req["comments"])
+				require.Equal(t, []interface{}{
+					map[string]interface{}{"name": "region", "value": "eu", "is_default": true},
+					map[string]interface{}{"name": "dryRun", "value": "false", "is_default": true},
+				}, req["inputs"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "TIMED_OUT",
+				"ON_TIMEOUT":          "approve",
+				"INPUTS":              "region:\n  type: string\n  default: eu\nticket:\n  type: string\ndryRun:\n  type: boolean\n  default: false\n",
+			},
+			output: []string{
+				"Workflow timed out\n",
+				"Workflow approval response was not received within allotted time.\n",
+				"Automatically approving the manual approval request\n",
+				"Comments:\nAutomatically approved as the approval response was not received within allotted time.\n",
+				"\nInput Parameters:\n",
+				"------------------\n",
+				" region: eu (default) \n",
+				" dryRun: false (default) \n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request automatically approved after timing out\",\"status\":\"APPROVED\"}",
+			summary:      "## Manual approval: Approved\n\n| | |\n|---|---|\n| Decision | Approved |\n| Decided on | 2009-11-10T23:30:00Z |\n\n### Input parameters\n\n| Name | Value |\n|---|---|\n| region | eu (default) |\n| dryRun | false (default) |\n\n### Comments\n\n> Automatically approved as the approval response was not received within allotted time.\n",
+			comments:     "Automatically approved as the approval response was not received within allotted time.",
+			inputValues:  "{\"dryRun\":false,\"region\":\"eu\"}",
+			err:          "",
+		},
+		{
+			name: "TIMED_OUT not approved automatically without a reason code",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT"}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "TIMED_OUT",
+				"ON_TIMEOUT":          "approve",
+				"REASON_CODES":        "hotfix,planned",
+			},
+			output: []string{
+				"WARNING: The approval request is not approved automatically: a reason code is required, valid values are: hotfix, planned\n",
+				"Workflow timed out\n",
+				"Workflow approval response was not received within allotted time.\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request timed out\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Timed out\n\n| | |\n|---|---|\n| Decision | Timed out |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "TIMED_OUT not approved automatically without a required comment",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT"}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "TIMED_OUT",
+				"ON_TIMEOUT":          "approve",
+				"REQUIRE_COMMENT":     "approved",
+			},
+			output: []string{
+				"WARNING: The approval request is not approved automatically: a comment is required when the request is approved\n",
+				"Workflow timed out\n",
+				"Workflow approval response was not received within allotted time.\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request timed out\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Timed out\n\n| | |\n|---|---|\n| Decision | Timed out |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "TIMED_OUT not approved automatically without a required input value",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT"}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "TIMED_OUT",
+				"ON_TIMEOUT":          "approve",
+				"INPUTS":              "ticket:\n  type: string\n  required: true\n",
+			},
+			output: []string{
+				"WARNING: The approval request is not approved automatically: input 'ticket' is required and has no default value\n",
+				"Workflow timed out\n",
+				"Workflow approval response was not received within allotted time.\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request timed out\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Timed out\n\n| | |\n|---|---|\n| Decision | Timed out |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "TIMED_OUT rejected automatically",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED", req["status"])
+				require.Equal(t, "Automatically rejected as the approval response was not received within allotted time.\n\nAsk the release team to approve the deployment", req["comments"])
+				require.Equal(t, []interface{}{}, req["inputs"])
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "TIMED_OUT",
+				"ON_TIMEOUT":          "reject",
+				"ON_TIMEOUT_COMMENTS": "Ask the release team to approve the deployment",
+			},
+			output: []string{
+				"Workflow timed out\n",
+				"Workflow approval response was not received within allotted time.\n",
+				"Automatically rejecting the manual approval request\n",
+				"Comments:\nAutomatically rejected as the approval response was not received within allotted time.\n\nAsk the release team to approve the deployment\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request automatically rejected after timing out\",\"status\":\"REJECTED\"}",
+			summary:      "## Manual approval: Rejected\n\n| | |\n|---|---|\n| Decision | Rejected |\n| Decided on | 2009-11-10T23:30:00Z |\n\n### Comments\n\n> Automatically rejected as the approval response was not received within allotted time.\n>\n> Ask the release team to approve the deployment\n",
+			comments:     "Automatically rejected as the approval response was not received within allotted time.\n\nAsk the release team to approve the deployment",
+			inputValues:  "{}",
+			err:          "",
 		},
 		{
-			name: "failure",
+			name: "CANCELLED with onTimeout approve",
 			reqCheckFunc: func(req map[string]interface{}) {
-				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", req["status"].(string))
-				require.Equal(t, "test comments", req["comments"].(string))
-				require.Equal(t, "123", req["userId"].(string))
-				require.Equal(t, "testUserName", req["userName"].(string))
-				require.Equal(t, "2009-11-10T23:00:00Z", req["respondedOn"].(string))
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED"}, req)
 			},
 			respGenFunc: func() (*http.Response, error) {
 				return &http.Response{
-					StatusCode: 500,
-					Status:     "500 Internal Server Error",
-					Body:       io.NopCloser(bytes.NewBufferString(`wrong parameter`)),
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
 				}, nil
 			},
 			env: map[string]string{
-				"URL":              "http://test.com",
-				"API_TOKEN":        "test",
-				"CLOUDBEES_STATUS": "/tmp/test-status-out",
-				"PAYLOAD":          "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"test comments\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "CANCELLED",
+				"ON_TIMEOUT":          "approve",
 			},
-			statusInFile: "{\"message\":\"Failed to change workflow manual approval status: 'failed to send event: \\nPOST http://test.com/v1/workflows/approval/status\\nHTTP/500 500 Internal Server Error\\n'\",\"status\":\"FAILED\"}",
 			output: []string{
-				"ERROR: API call failed with error: 'failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n'\n",
-				"ERROR: API response: 'wrong parameter'\n",
+				"Workflow aborted by user\n",
+				"Cancelling the manual approval request\n",
 			},
-			err: "failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n",
+			statusInFile: "{\"message\":\"Workflow manual approval request aborted\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Aborted\n\n| | |\n|---|---|\n| Decision | Aborted |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Prepare
-			for k, v := range tt.env {
-				os.Setenv(k, v)
-				defer func(k string) {
-					os.Unsetenv(k)
-				}(k)
-			}
-			outputs_dir, exists := tt.env["CLOUDBEES_OUTPUTS"]
-			if exists {
-				os.Mkdir(outputs_dir, 0755)
-				defer func(dir string) {
-					os.RemoveAll(dir)
-				}(outputs_dir)
-			}
-
-			var testOutput []string
-
-			// Run
-			c := Config{
-				Client: &MockHttpClient{
-					MockDo: func(req *http.Request) (*http.Response, error) {
-						require.NotNil(t, req)
-						require.Equal(t, "POST", req.Method)
-						require.Equal(t, "http://test.com/v1/workflows/approval/status", req.URL.String())
-						require.Equal(t, "application/json", req.Header.Get("Content-Type"))
-						require.Equal(t, "application/json", req.Header.Get("Accept"))
-						require.Contains(t, req.Header.Get("Authorization"), "Bearer ")
-
-						reqBody := make(map[string]interface{})
-						bodyReader, err := req.GetBody()
-						require.NoError(t, err)
-						body, err := io.ReadAll(bodyReader)
-						require.NoError(t, err)
-						err = json.Unmarshal(body, &reqBody)
-						require.NoError(t, err)
-
-						// Check parsed request body
-						tt.reqCheckFunc(reqBody)
-
-						// Generate response
-						return tt.respGenFunc()
-					},
-				},
-				Output: &MockStdOut{
-					MockPrintf: func(format string, a ...any) {
-						testOutput = append(testOutput, fmt.Sprintf(format, a...))
-						fmt.Printf(format, a...)
-					},
-					MockPrintln: func(a ...any) {
-						testOutput = append(testOutput, fmt.Sprintln(a...))
-						fmt.Println(a...)
-					},
-				},
-			}
-			err := c.callback()
-
-			// Verify
-			if tt.err == "" {
-				require.NoError(t, err)
-			} else {
-				require.Error(t, err)
-				require.Equal(t, tt.err, err.Error())
-			}
-
-			if tt.inputValsInOutput != "" {
-				out, ferr := os.ReadFile(tt.env["CLOUDBEES_OUTPUTS"] + "/approvalInputValues")
-				require.NoError(t, ferr)
-				require.Equal(t, tt.inputValsInOutput, string(out))
-			}
-
-			if tt.commentsInOutput != "" {
-				out, ferr := os.ReadFile(tt.env["CLOUDBEES_OUTPUTS"] + "/comments")
-				require.NoError(t, ferr)
-				require.Equal(t, tt.commentsInOutput, string(out))
-			}
-
-			out, ferr := os.ReadFile(tt.env["CLOUDBEES_STATUS"])
-			require.NoError(t, ferr)
-			require.Equal(t, tt.statusInFile, string(out))
-
-			require.True(t, slices.Equal(tt.output, testOutput))
-		})
-	}
-}
-
-func Test_cancel(t *testing.T) {
-	tests := []struct {
-		name         string
-		reqCheckFunc func(req map[string]interface{})
-		respGenFunc  func() (*http.Response, error)
-		env          map[string]string
-		client       *MockHttpClient
-		output       []string
-		err          string
-	}{
 		{
-			name: "success CANCELLED",
+			name: "unknown reason",
 			reqCheckFunc: func(req map[string]interface{}) {
-				require.NotNil(t, req["status"])
-				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED", req["status"].(string))
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED"}, req)
 			},
 			respGenFunc: func() (*http.Response, error) {
 				return &http.Response{
@@ -696,19 +1892,50 @@ func Test_cancel(t *testing.T) {
 			env: map[string]string{
 				"URL":                 "http://test.com",
 				"API_TOKEN":           "test",
-				"CANCELLATION_REASON": "CANCELLED",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "test reason",
 			},
 			output: []string{
-				"Workflow aborted by user\n",
+				"WARNING: Workflow cancelled for an unknown reason 'test reason'\n",
 				"Cancelling the manual approval request\n",
 			},
-			err: "",
+			statusInFile: "{\"message\":\"Workflow manual approval request cancelled for an unknown reason\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Cancelled\n\n| | |\n|---|---|\n| Decision | Cancelled |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
 		},
 		{
-			name: "success TIMED_OUT",
+			name: "unknown reason with onTimeout approve",
 			reqCheckFunc: func(req map[string]interface{}) {
-				require.NotNil(t, req["status"])
-				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT", req["status"].(string))
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED"}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "WORKFLOW_FAILED",
+				"ON_TIMEOUT":          "approve",
+			},
+			output: []string{
+				"WARNING: Workflow cancelled for an unknown reason 'WORKFLOW_FAILED'\n",
+				"Cancelling the manual approval request\n",
+			},
+			statusInFile: "{\"message\":\"Workflow manual approval request cancelled for an unknown reason\",\"status\":\"FAILED\"}",
+			summary:      "## Manual approval: Cancelled\n\n| | |\n|---|---|\n| Decision | Cancelled |\n| Decided on | 2009-11-10T23:30:00Z |\n",
+			err:          "",
+		},
+		{
+			name: "failure invalid onTimeout",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_TIMED_OUT"}, req)
 			},
 			respGenFunc: func() (*http.Response, error) {
 				return &http.Response{
@@ -720,13 +1947,42 @@ func Test_cancel(t *testing.T) {
 			env: map[string]string{
 				"URL":                 "http://test.com",
 				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
 				"CANCELLATION_REASON": "TIMED_OUT",
+				"ON_TIMEOUT":          "retry",
 			},
 			output: []string{
-				"Workflow timed out\n",
-				"Workflow approval response was not received within allotted time.\n",
+				"ERROR: unsupported onTimeout value 'retry', valid values are: fail, approve, reject\n",
 			},
-			err: "",
+			statusInFile: "{\"message\":\"unsupported onTimeout value 'retry', valid values are: fail, approve, reject\",\"status\":\"FAILED\"}",
+			err:          "unsupported onTimeout value 'retry', valid values are: fail, approve, reject",
+		},
+		{
+			name: "failure invalid approval state",
+			reqCheckFunc: func(req map[string]interface{}) {
+				require.Equal(t, map[string]interface{}{"status": "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED"}, req)
+			},
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+				"CLOUDBEES_OUTPUTS":   "/tmp/test-outputs",
+				"CANCELLATION_REASON": "CANCELLED",
+				"APPROVAL_STATE":      "{\"version\":1,",
+			},
+			output: []string{
+				"ERROR: failed to load the approval state: unexpected end of JSON input\n",
+			},
+			statusInFile: "{\"message\":\"failed to load the approval state: unexpected end of JSON input\",\"status\":\"FAILED\"}",
+			err:          "failed to load the approval state: unexpected end of JSON input",
 		},
 		{
 			name: "failure",
@@ -744,6 +2000,7 @@ func Test_cancel(t *testing.T) {
 			env: map[string]string{
 				"URL":                 "http://test.com",
 				"API_TOKEN":           "test",
+				"CLOUDBEES_STATUS":    "/tmp/test-status-out",
 				"CANCELLATION_REASON": "TIMED_OUT",
 			},
 			output: []string{
@@ -752,7 +2009,8 @@ func Test_cancel(t *testing.T) {
 				"ERROR: API call failed with error: 'failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n'\n",
 				"ERROR: API response: 'wrong parameter'\n",
 			},
-			err: "failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n",
+			statusInFile: "{\"message\":\"Failed to cancel workflow manual approval request: 'failed to send event: \\nPOST http://test.com/v1/workflows/approval/status\\nHTTP/500 500 Internal Server Error\\n'\",\"status\":\"FAILED\"}",
+			err:          "failed to send event: \nPOST http://test.com/v1/workflows/approval/status\nHTTP/500 500 Internal Server Error\n",
 		},
 	}
 	for _, tt := range tests {
@@ -764,6 +2022,19 @@ func Test_cancel(t *testing.T) {
 					os.Unsetenv(k)
 				}(k)
 			}
+			outputs_dir, exists := tt.env["CLOUDBEES_OUTPUTS"]
+			if exists {
+				os.Mkdir(outputs_dir, 0755)
+				defer func(dir string) {
+					os.RemoveAll(dir)
+				}(outputs_dir)
+			}
+			defer func(f func() time.Time) {
+				now = f
+			}(now)
+			now = func() time.Time {
+				return time.Date(2009, 11, 10, 23, 30, 0, 0, time.UTC)
+			}
 
 			var testOutput []string
 
@@ -807,8 +2078,22 @@ func Test_cancel(t *testing.T) {
 			err := c.cancel()
 
 			// Verify
+			out, ferr := os.ReadFile(tt.env["CLOUDBEES_STATUS"])
+			require.NoError(t, ferr)
+			require.Equal(t, tt.statusInFile, string(out))
 			if tt.err == "" {
 				require.NoError(t, err)
+				out, ferr = os.ReadFile(filepath.Join(tt.env["CLOUDBEES_OUTPUTS"], "approvalSummary"))
+				require.NoError(t, ferr)
+				require.Equal(t, tt.summary, string(out))
+				if tt.comments != "" {
+					out, ferr = os.ReadFile(filepath.Join(tt.env["CLOUDBEES_OUTPUTS"], "comments"))
+					require.NoError(t, ferr)
+					require.Equal(t, tt.comments, string(out))
+					out, ferr = os.ReadFile(filepath.Join(tt.env["CLOUDBEES_OUTPUTS"], "approvalInputValues"))
+					require.NoError(t, ferr)
+					require.Equal(t, tt.inputValues, string(out))
+				}
 			} else {
 				require.Error(t, err)
 				require.Equal(t, tt.err, err.Error())
@@ -830,6 +2115,11 @@ func Test_markdown(t *testing.T) {
 			input:  instructionsInput,
 			output: instructionsOutput,
 		},
+		{
+			name:   "GitHub-flavored markdown",
+			input:  "| a | b |\n|---|---|\n| 1 | 2 |\n\n- [ ] todo\n\n~~old~~ https://example.com",
+			output: "<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>\n<ul>\n<li><input disabled=\"\" type=\"checkbox\"> todo</li>\n</ul>\n<p><del>old</del> <a href=\"https://example.com\">https://example.com</a></p>\n",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -841,3 +2131,144 @@ func Test_markdown(t *testing.T) {
 		})
 	}
 }
+
+func Test_dryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler string
+		env     map[string]string
+		output  []string
+	}{
+		{
+			name:    "init",
+			handler: "init",
+			env: map[string]string{
+				"URL":            "http://test.com",
+				"API_TOKEN":      "test",
+				"APPROVERS":      "123",
+				"CALLBACK_TOKEN": "test-callback-token",
+				"INPUTS":         "in1:\n  type: string\n",
+			},
+			output: []string{
+				"DRY RUN: POST http://test.com/v1/workflows/approval\n",
+				"DRY RUN: Authorization: Bearer ********\n",
+				"DRY RUN: request body:\n{\n  \"approvalInputs\": \"in1:\\n  type: string\\n\",\n  \"approvers\": [\n    \"123\"\n  ],\n  \"disallowLaunchByUser\": false,\n  \"notifyEligibleUsers\": false,\n  \"token\": \"********\"\n}\n",
+				"Waiting for approval from one of the following: \n",
+				"DRY RUN: output 'approvalState':\n{\"version\":1,\"approvalId\":\"1234\",\"approvers\":[\"123\"],\"inputs\":\"in1:\\n  type: string\\n\",\"requestedOn\":\"2009-11-10T23:30:00Z\"}\n",
+				"DRY RUN: status PENDING_APPROVAL: Waiting for approval from approvers\n",
+			},
+		},
+		{
+			name:    "callback",
+			handler: "callback",
+			env: map[string]string{
+				"URL":            "http://test.com",
+				"API_TOKEN":      "test",
+				"PAYLOAD":        "{\"status\":\"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\"comments\":\"lgtm\",\"userId\":\"123\",\"userName\":\"testUserName\",\"respondedOn\":\"2009-11-10T23:00:00Z\"}",
+				"APPROVAL_STATE": "{\"version\":1,\"approvalId\":\"1234\",\"approvers\":[\"123\"],\"requestedOn\":\"2009-11-10T22:00:00Z\"}",
+			},
+			output: []string{
+				"DRY RUN: POST http://test.com/v1/workflows/approval/status\n",
+				"DRY RUN: Authorization: Bearer ********\n",
+				"DRY RUN: request body:\n{\n  \"comments\": \"lgtm\",\n  \"respondedOn\": \"2009-11-10T23:00:00Z\",\n  \"status\": \"UPDATE_MANUAL_APPROVAL_STATUS_APPROVED\",\n  \"userId\": \"123\",\n  \"userName\": \"testUserName\"\n}\n",
+				"Approved by testUserName on 2009-11-10T23:00:00Z with comments:\nlgtm\n",
+				"DRY RUN: output 'approvalInputValues':\n{}\n",
+				"DRY RUN: output 'approvalInputsEnv':\n\n",
+				"DRY RUN: output 'comments':\nlgtm\n",
+				"DRY RUN: output 'timeToDecision':\n3600\n",
+				"DRY RUN: output 'approvalState':\n{\"version\":1,\"approvalId\":\"1234\",\"approvers\":[\"123\"],\"requestedOn\":\"2009-11-10T22:00:00Z\",\"decidedOn\":\"2009-11-10T23:00:00Z\",\"outcome\":\"approved\"}\n",
+				"DRY RUN: output 'approvalSummary':\n## Manual approval: Approved\n\n| | |\n|---|---|\n| Decision | Approved |\n| Approver | testUserName |\n| Requested on | 2009-11-10T22:00:00Z |\n| Decided on | 2009-11-10T23:00:00Z |\n| Time waited | 1h0m0s |\n\n### Comments\n\n> lgtm\n\n",
+				"DRY RUN: output 'approvalSummaryHtml':\n<h2>Manual approval: Approved</h2>\n<table>\n<tr><th>Decision</th><td>Approved</td></tr>\n<tr><th>Approver</th><td>testUserName</td></tr>\n<tr><th>Requested on</th><td>2009-11-10T22:00:00Z</td></tr>\n<tr><th>Decided on</th><td>2009-11-10T23:00:00Z</td></tr>\n<tr><th>Time waited</th><td>1h0m0s</td></tr>\n</table>\n<h3>Comments</h3>\n<blockquote>lgtm</blockquote>\n\n",
+				"DRY RUN: status APPROVED: Successfully changed workflow manual approval status\n",
+			},
+		},
+		{
+			name:    "cancel",
+			handler: "cancel",
+			env: map[string]string{
+				"URL":                 "http://test.com",
+				"API_TOKEN":           "test",
+				"CANCELLATION_REASON": "CANCELLED",
+				"APPROVAL_STATE":      "{\"version\":1,\"approvalId\":\"1234\",\"approvers\":[\"123\"],\"requestedOn\":\"2009-11-10T23:00:00Z\"}",
+				"METRICS_EXPORTER":    "prometheus",
+				"METRICS_FILE":        "/tmp/manual-approval.prom",
+				"METRICS_LABELS":      "workflow=deploy",
+			},
+			output: []string{
+				"Workflow aborted by user\n",
+				"Cancelling the manual approval request\n",
+				"Approval was requested on 2009-11-10T23:00:00Z from: 123\n",
+				"DRY RUN: POST http://test.com/v1/workflows/approval/status\n",
+				"DRY RUN: Authorization: Bearer ********\n",
+				"DRY RUN: request body:\n{\n  \"status\": \"UPDATE_MANUAL_APPROVAL_STATUS_ABORTED\"\n}\n",
+				"DRY RUN: output 'timeToDecision':\n1800\n",
+				"DRY RUN: metrics '/tmp/manual-approval.prom':\n" +
+					"# HELP manual_approval_decision_duration_seconds Time from the approval request to the latest decision.\n" +
+					"# TYPE manual_approval_decision_duration_seconds gauge\n" +
+					"manual_approval_decision_duration_seconds{outcome=\"aborted\",workflow=\"deploy\"} 1800\n" +
+					"# HELP manual_approval_decisions_total Number of decided approval requests.\n" +
+					"# TYPE manual_approval_decisions_total counter\n" +
+					"manual_approval_decisions_total{outcome=\"aborted\",workflow=\"deploy\"} 1\n",
+				"DRY RUN: output 'approvalState':\n{\"version\":1,\"approvalId\":\"1234\",\"approvers\":[\"123\"],\"requestedOn\":\"2009-11-10T23:00:00Z\",\"decidedOn\":\"2009-11-10T23:30:00Z\",\"outcome\":\"aborted\"}\n",
+				"DRY RUN: output 'approvalSummary':\n## Manual approval: Aborted\n\n| | |\n|---|---|\n| Decision | Aborted |\n| Requested on | 2009-11-10T23:00:00Z |\n| Decided on | 2009-11-10T23:30:00Z |\n| Time waited | 30m0s |\n\n",
+				"DRY RUN: output 'approvalSummaryHtml':\n<h2>Manual approval: Aborted</h2>\n<table>\n<tr><th>Decision</th><td>Aborted</td></tr>\n<tr><th>Requested on</th><td>2009-11-10T23:00:00Z</td></tr>\n<tr><th>Decided on</th><td>2009-11-10T23:30:00Z</td></tr>\n<tr><th>Time waited</th><td>30m0s</td></tr>\n</table>\n\n",
+				"DRY RUN: status FAILED: Workflow manual approval request aborted\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			defer func(l *slog.Logger) {
+				logger = l
+			}(logger)
+			os.Setenv("LOG_LEVEL", "info")
+			defer os.Unsetenv("LOG_LEVEL")
+			defer func(f func() time.Time) {
+				now = f
+			}(now)
+			now = func() time.Time {
+				return time.Date(2009, 11, 10, 23, 30, 0, 0, time.UTC)
+			}
+			defer func(f func() (string, error)) {
+				newApprovalId = f
+			}(newApprovalId)
+			newApprovalId = func() (string, error) {
+				return "1234", nil
+			}
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+
+			var testOutput []string
+
+			// Run
+			c := Config{
+				Handler: tt.handler,
+				DryRun:  true,
+				Client: &MockHttpClient{
+					MockDo: func(req *http.Request) (*http.Response, error) {
+						require.Fail(t, "the API must not be called")
+						return nil, nil
+					},
+				},
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+					},
+					MockPrintln: func(a ...any) {
+						testOutput = append(testOutput, fmt.Sprintln(a...))
+					},
+				},
+			}
+			err := c.Run(context.Background())
+
+			// Verify
+			require.NoError(t, err)
+			require.Equal(t, tt.output, testOutput)
+		})
+	}
+}
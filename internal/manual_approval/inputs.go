@@ -0,0 +1,347 @@
+package manual_approval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported approval input types
+const (
+	inputTypeString      = "string"
+	inputTypeNumber      = "number"
+	inputTypeBoolean     = "boolean"
+	inputTypeChoice      = "choice"
+	inputTypeMultichoice = "multichoice"
+	inputTypeDate        = "date"
+	inputTypeDatetime    = "datetime"
+	inputTypeSecret      = "secret"
+)
+
+var inputTypes = []string{
+	inputTypeString,
+	inputTypeNumber,
+	inputTypeBoolean,
+	inputTypeChoice,
+	inputTypeMultichoice,
+	inputTypeDate,
+	inputTypeDatetime,
+	inputTypeSecret,
+}
+
+// Layout of date input values, datetime input values use RFC 3339
+const dateLayout = "2006-01-02"
+
+// Replacement of secret input values in logs, outputs and the approval status
+const secretMask = "********"
+
+// inputDefinition is a single approval input declared in the approvalInputs YAML.
+// When maps input names to the value or list of values for which the input is shown.
+type inputDefinition struct {
+	Name        string                 `yaml:"-"`
+	Type        string                 `yaml:"type"`
+	Required    bool                   `yaml:"required,omitempty"`
+	Default     interface{}            `yaml:"default,omitempty"`
+	Description string                 `yaml:"description,omitempty"`
+	Options     []string               `yaml:"options,omitempty"`
+	OptionsFrom string                 `yaml:"optionsFrom,omitempty"`
+	Pattern     string                 `yaml:"pattern,omitempty"`
+	MinLength   *int                   `yaml:"minLength,omitempty"`
+	MaxLength   *int                   `yaml:"maxLength,omitempty"`
+	Min         *float64               `yaml:"min,omitempty"`
+	Max         *float64               `yaml:"max,omitempty"`
+	When        map[string]interface{} `yaml:"when,omitempty"`
+}
+
+// Parse the approvalInputs YAML into the mapping of input names to definitions
+func parseInputsNode(inputs string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(unescapeInputs(inputs)), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a mapping of input names to definitions")
+	}
+	return root, nil
+}
+
+// The approvalInputs can be passed on a single line with the line breaks escaped as \n,
+// which is how they were always forwarded to the API, so both forms are accepted
+func unescapeInputs(inputs string) string {
+	if strings.Contains(inputs, "\n") || !strings.Contains(inputs, `\n`) {
+		return inputs
+	}
+	return strings.ReplaceAll(inputs, `\n`, "\n")
+}
+
+// Get the names of the approval inputs in the order they are declared
+func inputNames(inputs string) ([]string, error) {
+	root, err := parseInputsNode(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(root.Content)/2)
+	for i := 0; i < len(root.Content); i += 2 {
+		names = append(names, root.Content[i].Value)
+	}
+	return names, nil
+}
+
+// Parse and validate the approval input definitions in the order they are declared
+func parseInputs(inputs string) ([]inputDefinition, error) {
+	root, err := parseInputsNode(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make([]inputDefinition, 0, len(root.Content)/2)
+	for i := 0; i < len(root.Content); i += 2 {
+		def := inputDefinition{}
+		if err := root.Content[i+1].Decode(&def); err != nil {
+			return nil, fmt.Errorf("input '%s': %w", root.Content[i].Value, err)
+		}
+		def.Name = root.Content[i].Value
+		if err := def.validateDefinition(); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+
+	if err := validateConditions(defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+func (d inputDefinition) validateDefinition() error {
+	if !slices.Contains(inputTypes, d.Type) {
+		return fmt.Errorf("input '%s': unsupported type '%s', valid types are: %s", d.Name, d.Type, strings.Join(inputTypes, ", "))
+	}
+
+	isChoice := d.Type == inputTypeChoice || d.Type == inputTypeMultichoice
+	if isChoice && len(d.Options) == 0 && d.OptionsFrom == "" {
+		return fmt.Errorf("input '%s': options or optionsFrom are required for type '%s'", d.Name, d.Type)
+	}
+	if d.OptionsFrom != "" && !isChoice {
+		return fmt.Errorf("input '%s': optionsFrom is only supported for types choice and multichoice", d.Name)
+	}
+	if d.OptionsFrom != "" && len(d.Options) > 0 {
+		return fmt.Errorf("input '%s': options and optionsFrom cannot be used together", d.Name)
+	}
+
+	isText := d.Type == inputTypeString || d.Type == inputTypeSecret
+	if !isText && (d.Pattern != "" || d.MinLength != nil || d.MaxLength != nil) {
+		return fmt.Errorf("input '%s': pattern, minLength and maxLength are only supported for types string and secret", d.Name)
+	}
+	if d.Pattern != "" {
+		if _, err := regexp.Compile(d.Pattern); err != nil {
+			return fmt.Errorf("input '%s': invalid pattern: %w", d.Name, err)
+		}
+	}
+	if d.MinLength != nil && d.MaxLength != nil && *d.MinLength > *d.MaxLength {
+		return fmt.Errorf("input '%s': minLength must not be greater than maxLength", d.Name)
+	}
+
+	if d.Type != inputTypeNumber && (d.Min != nil || d.Max != nil) {
+		return fmt.Errorf("input '%s': min and max are only supported for type number", d.Name)
+	}
+	if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+		return fmt.Errorf("input '%s': min must not be greater than max", d.Name)
+	}
+	return nil
+}
+
+// Check a value provided by the approver against the type and constraints of the input
+func (d inputDefinition) validateValue(value interface{}) error {
+	// the options of an optionsFrom are resolved when the approval is requested and read from
+	// the approval state, without them no value can be accepted
+	if len(d.Options) == 0 && d.OptionsFrom != "" {
+		return fmt.Errorf("input '%s': the options resolved from optionsFrom are not available, the approval state is missing", d.Name)
+	}
+
+	switch d.Type {
+	case inputTypeString, inputTypeSecret:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("input '%s': expected a string value", d.Name)
+		}
+		return d.validateText(s)
+	case inputTypeNumber:
+		n, ok := numberValue(value)
+		if !ok {
+			return fmt.Errorf("input '%s': expected a number value", d.Name)
+		}
+		if d.Min != nil && n < *d.Min {
+			return fmt.Errorf("input '%s': value must be at least %v", d.Name, *d.Min)
+		}
+		if d.Max != nil && n > *d.Max {
+			return fmt.Errorf("input '%s': value must be at most %v", d.Name, *d.Max)
+		}
+	case inputTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("input '%s': expected a boolean value", d.Name)
+		}
+	case inputTypeChoice:
+		s, ok := value.(string)
+		if !ok || !d.hasOption(s) {
+			return fmt.Errorf("input '%s': value must be one of: %s", d.Name, strings.Join(d.Options, ", "))
+		}
+	case inputTypeMultichoice:
+		values, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("input '%s': expected a list of values", d.Name)
+		}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok || !d.hasOption(s) {
+				return fmt.Errorf("input '%s': values must be in: %s", d.Name, strings.Join(d.Options, ", "))
+			}
+		}
+	case inputTypeDate:
+		s, _ := value.(string)
+		if _, err := time.Parse(dateLayout, s); err != nil {
+			return fmt.Errorf("input '%s': expected a date in the format YYYY-MM-DD", d.Name)
+		}
+	case inputTypeDatetime:
+		s, _ := value.(string)
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return fmt.Errorf("input '%s': expected an RFC 3339 date and time", d.Name)
+		}
+	}
+	return nil
+}
+
+func (d inputDefinition) hasOption(value string) bool {
+	return slices.Contains(d.Options, value)
+}
+
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func (d inputDefinition) validateText(s string) error {
+	length := utf8.RuneCountInString(s)
+	if d.MinLength != nil && length < *d.MinLength {
+		return fmt.Errorf("input '%s': value must be at least %d characters long", d.Name, *d.MinLength)
+	}
+	if d.MaxLength != nil && length > *d.MaxLength {
+		return fmt.Errorf("input '%s': value must be at most %d characters long", d.Name, *d.MaxLength)
+	}
+	if d.Pattern != "" && !regexp.MustCompile(d.Pattern).MatchString(s) {
+		if d.Type == inputTypeSecret {
+			return fmt.Errorf("input '%s': value does not match the pattern", d.Name)
+		}
+		return fmt.Errorf("input '%s': value '%s' does not match the pattern '%s'", d.Name, s, d.Pattern)
+	}
+	return nil
+}
+
+// Check the values provided by the approver against the declared inputs.
+// Conditional inputs are only checked when their conditions are met.
+func validateInputValues(defs []inputDefinition, inputValues map[string]interface{}) error {
+	hidden := hiddenInputs(defs, inputValues)
+	for _, def := range defs {
+		if hidden[def.Name] {
+			continue
+		}
+
+		value, ok := inputValues[def.Name]
+		if def.Required && len(def.When) > 0 && (!ok || value == nil || value == "") {
+			return fmt.Errorf("input '%s' is required when %s", def.Name, def.describeConditions())
+		}
+		if !ok || value == nil {
+			continue
+		}
+		if err := def.validateValue(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the input values provided by the approver keyed by input name
+func payloadInputValues(parsedPayload map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	inputs, _ := parsedPayload["inputs"].([]interface{})
+	for _, input := range inputs {
+		if ip, ok := input.(map[string]interface{}); ok {
+			if name, ok := ip["name"].(string); ok {
+				values[name] = ip["value"]
+			}
+		}
+	}
+	return values
+}
+
+// Get the names of the secret inputs, their values must never be logged or written to outputs
+func secretInputs(defs []inputDefinition) map[string]bool {
+	secrets := make(map[string]bool)
+	for _, def := range defs {
+		if def.Type == inputTypeSecret {
+			secrets[def.Name] = true
+		}
+	}
+	return secrets
+}
+
+// Append generated input definitions to the approvalInputs YAML, keeping the declared inputs as they are
+func appendInputs(inputs string, defs []inputDefinition) (string, error) {
+	root, err := parseInputsNode(inputs)
+	if err != nil {
+		return "", err
+	}
+
+	declared := make(map[string]bool)
+	for i := 0; i < len(root.Content); i += 2 {
+		declared[root.Content[i].Value] = true
+	}
+
+	for _, def := range defs {
+		if declared[def.Name] {
+			return "", fmt.Errorf("input '%s' is reserved and cannot be declared in approvalInputs", def.Name)
+		}
+		value := &yaml.Node{}
+		if err := value.Encode(def); err != nil {
+			return "", err
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: def.Name}, value)
+	}
+
+	return marshalInputs(root)
+}
+
+func marshalInputs(root *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,238 @@
+package manual_approval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_appendInputs(t *testing.T) {
+	checklist := []inputDefinition{
+		{Name: "checklist-1", Type: "boolean", Required: true, Default: false, Description: "I have verified the rollback plan"},
+	}
+
+	tests := []struct {
+		name   string
+		inputs string
+		defs   []inputDefinition
+		output string
+		err    string
+	}{
+		{
+			name:   "no declared inputs",
+			defs:   checklist,
+			output: "checklist-1:\n  type: boolean\n  required: true\n  default: false\n  description: I have verified the rollback plan\n",
+		},
+		{
+			name:   "declared inputs are kept",
+			inputs: "in1:\n  type: string # free text\n  required: true\n",
+			defs:   checklist,
+			output: "in1:\n  type: string # free text\n  required: true\nchecklist-1:\n  type: boolean\n  required: true\n  default: false\n  description: I have verified the rollback plan\n",
+		},
+		{
+			name:   "reserved name",
+			inputs: "checklist-1:\n  type: string\n",
+			defs:   checklist,
+			err:    "input 'checklist-1' is reserved and cannot be declared in approvalInputs",
+		},
+		{
+			name:   "not a mapping",
+			inputs: "- in1\n",
+			defs:   checklist,
+			err:    "expected a mapping of input names to definitions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			result, err := appendInputs(tt.inputs, tt.defs)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, result)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_parseInputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs string
+		output []string
+		err    string
+	}{
+		{
+			name:   "no inputs",
+			inputs: "",
+			output: []string{},
+		},
+		{
+			name:   "all types",
+			inputs: "s:\n  type: string\n  pattern: ^v[0-9]+$\n  minLength: 2\n  maxLength: 10\nn:\n  type: number\n  min: 1\n  max: 5\nb:\n  type: boolean\nc:\n  type: choice\n  options: [a, b]\nm:\n  type: multichoice\n  options: [a, b]\nd:\n  type: date\ndt:\n  type: datetime\np:\n  type: secret\n",
+			output: []string{"s", "n", "b", "c", "m", "d", "dt", "p"},
+		},
+		{
+			name:   "escaped line breaks",
+			inputs: approvalInputs,
+			output: []string{"in1", "in2", "in3"},
+		},
+		{
+			name:   "unsupported type",
+			inputs: "in1:\n  type: list\n",
+			err:    "input 'in1': unsupported type 'list', valid types are: string, number, boolean, choice, multichoice, date, datetime, secret",
+		},
+		{
+			name:   "missing options",
+			inputs: "in1:\n  type: multichoice\n",
+			err:    "input 'in1': options or optionsFrom are required for type 'multichoice'",
+		},
+		{
+			name:   "optionsFrom on a string",
+			inputs: "in1:\n  type: string\n  optionsFrom: options.json\n",
+			err:    "input 'in1': optionsFrom is only supported for types choice and multichoice",
+		},
+		{
+			name:   "options and optionsFrom",
+			inputs: "in1:\n  type: choice\n  options: [a]\n  optionsFrom: options.json\n",
+			err:    "input 'in1': options and optionsFrom cannot be used together",
+		},
+		{
+			name:   "invalid pattern",
+			inputs: "in1:\n  type: string\n  pattern: '['\n",
+			err:    "input 'in1': invalid pattern: error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			name:   "pattern on a number",
+			inputs: "in1:\n  type: number\n  pattern: ^1$\n",
+			err:    "input 'in1': pattern, minLength and maxLength are only supported for types string and secret",
+		},
+		{
+			name:   "min on a string",
+			inputs: "in1:\n  type: string\n  min: 1\n",
+			err:    "input 'in1': min and max are only supported for type number",
+		},
+		{
+			name:   "min greater than max",
+			inputs: "in1:\n  type: number\n  min: 5\n  max: 1\n",
+			err:    "input 'in1': min must not be greater than max",
+		},
+		{
+			name:   "minLength greater than maxLength",
+			inputs: "in1:\n  type: string\n  minLength: 5\n  maxLength: 1\n",
+			err:    "input 'in1': minLength must not be greater than maxLength",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			defs, err := parseInputs(tt.inputs)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				names := make([]string, len(defs))
+				for i, def := range defs {
+					names[i] = def.Name
+				}
+				require.Equal(t, tt.output, names)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_validateInputValues(t *testing.T) {
+	defs, err := parseInputs("s:\n  type: string\n  pattern: ^v[0-9]+$\n  minLength: 2\n  maxLength: 4\nn:\n  type: number\n  min: 1\n  max: 5\nb:\n  type: boolean\nc:\n  type: choice\n  options: [a, b]\nm:\n  type: multichoice\n  options: [a, b]\nd:\n  type: date\ndt:\n  type: datetime\np:\n  type: secret\n  pattern: ^[0-9]{6}$\n")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "valid values",
+			values: map[string]interface{}{"s": "v12", "n": 3.0, "b": true, "c": "a", "m": []interface{}{"a", "b"}, "d": "2024-02-29", "dt": "2024-02-29T10:00:00+01:00", "p": "123456"},
+		},
+		{
+			name:   "missing values are skipped",
+			values: map[string]interface{}{"s": nil},
+		},
+		{
+			name:   "string too short",
+			values: map[string]interface{}{"s": "v"},
+			err:    "input 's': value must be at least 2 characters long",
+		},
+		{
+			name:   "string too long",
+			values: map[string]interface{}{"s": "v12345"},
+			err:    "input 's': value must be at most 4 characters long",
+		},
+		{
+			name:   "string does not match pattern",
+			values: map[string]interface{}{"s": "x12"},
+			err:    "input 's': value 'x12' does not match the pattern '^v[0-9]+$'",
+		},
+		{
+			name:   "secret does not match pattern",
+			values: map[string]interface{}{"p": "12345"},
+			err:    "input 'p': value does not match the pattern",
+		},
+		{
+			name:   "number too small",
+			values: map[string]interface{}{"n": 0.5},
+			err:    "input 'n': value must be at least 1",
+		},
+		{
+			name:   "number too large",
+			values: map[string]interface{}{"n": 6.0},
+			err:    "input 'n': value must be at most 5",
+		},
+		{
+			name:   "not a boolean",
+			values: map[string]interface{}{"b": "yes"},
+			err:    "input 'b': expected a boolean value",
+		},
+		{
+			name:   "unknown choice",
+			values: map[string]interface{}{"c": "z"},
+			err:    "input 'c': value must be one of: a, b",
+		},
+		{
+			name:   "unknown multichoice",
+			values: map[string]interface{}{"m": []interface{}{"a", "z"}},
+			err:    "input 'm': values must be in: a, b",
+		},
+		{
+			name:   "invalid date",
+			values: map[string]interface{}{"d": "2023-02-29"},
+			err:    "input 'd': expected a date in the format YYYY-MM-DD",
+		},
+		{
+			name:   "invalid datetime",
+			values: map[string]interface{}{"dt": "2024-02-29 10:00"},
+			err:    "input 'dt': expected an RFC 3339 date and time",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			err := validateInputValues(defs, tt.values)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package manual_approval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Maximum size of the instructions loaded from a file or URL
+const maxInstructionsSize = 256 * 1024
+
+// Allowlist of html elements and attributes which are safe to render on the approval page
+var htmlPolicy = newHtmlPolicy()
+
+// Prefix of the approval inputs generated for the unchecked task list items in the instructions
+const checklistInputPrefix = "checklist-"
+
+func newHtmlPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// allow the disabled checkboxes of task lists
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// instructionsContext is the data made available to Go templates in the instructions
+type instructionsContext struct {
+	Env       map[string]string
+	Approvers []string
+	Inputs    []string
+	Vars      map[string]interface{}
+}
+
+// Get the instructions from exactly one of the inline text, a workspace file or a URL
+func (k *Config) loadInstructions(instructions string, file string, instructionsUrl string) (string, error) {
+	sources := 0
+	for _, s := range []string{instructions, file, instructionsUrl} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("only one of instructions, instructionsFile or instructionsUrl can be set")
+	}
+
+	switch {
+	case file != "":
+		data, err := readWorkspaceFile(file, maxInstructionsSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to read instructions file: %w", err)
+		}
+		return string(data), nil
+	case instructionsUrl != "":
+		data, err := k.download(instructionsUrl, maxInstructionsSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to download instructions: %w", err)
+		}
+		return string(data), nil
+	default:
+		return instructions, nil
+	}
+}
+
+// Remove any html which is not in the allowlist, e.g. scripts and event handlers
+func sanitizeHtml(html string) string {
+	return htmlPolicy.Sanitize(html)
+}
+
+// Expand Go template actions in the instructions, e.g. {{ .Vars.version }} or {{ .Env.GIT_COMMIT }}
+func renderInstructionsTemplate(instructions string, approvers []string, inputs string, vars string) (string, error) {
+	// plain text instructions are passed through untouched
+	if !strings.Contains(instructions, "{{") {
+		return instructions, nil
+	}
+
+	data := instructionsContext{
+		Env:       environMap(),
+		Approvers: approvers,
+		Vars:      map[string]interface{}{},
+	}
+
+	if inputs != "" {
+		names, err := inputNames(inputs)
+		if err != nil {
+			return "", fmt.Errorf("failed to render instructions template: invalid approvalInputs: %w", err)
+		}
+		data.Inputs = names
+	}
+
+	if vars != "" {
+		if err := json.Unmarshal([]byte(vars), &data.Vars); err != nil {
+			return "", fmt.Errorf("failed to render instructions template: instructionVars must be a JSON object: %w", err)
+		}
+	}
+
+	tmpl, err := template.New("instructions").Option("missingkey=error").Parse(instructions)
+	if err != nil {
+		return "", fmt.Errorf("failed to render instructions template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render instructions template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Environment variables which must never be exposed to the instructions template
+var sensitiveEnv = map[string]bool{
+	"API_TOKEN":      true,
+	"CALLBACK_TOKEN": true,
+}
+
+func environMap() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && !sensitiveEnv[k] {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// Get the text of the unchecked task list items, e.g. "- [ ] I have verified the rollback plan"
+func checklistItems(instructions string) []string {
+	source := []byte(instructions)
+	doc := md.Parser().Parse(text.NewReader(source))
+	r := &terminalRenderer{source: source}
+
+	var items []string
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		checkBox, ok := n.(*east.TaskCheckBox)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		if !checkBox.IsChecked {
+			item := strings.TrimPrefix(r.inline(checkBox.Parent()), "[ ] ")
+			items = append(items, strings.TrimSpace(item))
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return items
+}
+
+// Approval inputs the approvers have to tick for each unchecked task list item
+func checklistInputs(items []string) []inputDefinition {
+	defs := make([]inputDefinition, len(items))
+	for i, item := range items {
+		defs[i] = inputDefinition{
+			Name:        fmt.Sprintf("%s%d", checklistInputPrefix, i+1),
+			Type:        "boolean",
+			Required:    true,
+			Default:     false,
+			Description: item,
+		}
+	}
+	return defs
+}
@@ -0,0 +1,254 @@
+package manual_approval
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_renderInstructionsTemplate(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions string
+		approvers    []string
+		inputs       string
+		vars         string
+		env          map[string]string
+		output       string
+		err          string
+	}{
+		{
+			name:         "plain text is not expanded",
+			instructions: instructionsInput,
+			output:       instructionsInput,
+		},
+		{
+			name:         "env vars, approvers, inputs and vars",
+			instructions: "Deploy {{ .Vars.version }} ({{ .Env.GIT_COMMIT }}) to {{ .Vars.env }}\nApprovers: {{ range .Approvers }}{{ . }} {{ end }}\nInputs: {{ range .Inputs }}{{ . }} {{ end }}",
+			approvers:    []string{"123", "user@mail.com"},
+			inputs:       "in1:\n  type: string\nin2:\n  type: number\n",
+			vars:         `{"version": "1.2.3", "env": "production"}`,
+			env:          map[string]string{"GIT_COMMIT": "abc123"},
+			output:       "Deploy 1.2.3 (abc123) to production\nApprovers: 123 user@mail.com \nInputs: in1 in2 ",
+		},
+		{
+			name:         "sensitive env vars are hidden",
+			instructions: "{{ .Env.API_TOKEN }}",
+			env:          map[string]string{"API_TOKEN": "secret"},
+			err:          "failed to render instructions template: template: instructions:1:7: executing \"instructions\" at <.Env.API_TOKEN>: map has no entry for key \"API_TOKEN\"",
+		},
+		{
+			name:         "missing variable",
+			instructions: "Deploy {{ .Vars.version }}",
+			vars:         `{}`,
+			err:          "failed to render instructions template: template: instructions:1:15: executing \"instructions\" at <.Vars.version>: map has no entry for key \"version\"",
+		},
+		{
+			name:         "syntax error",
+			instructions: "Deploy {{ .Vars.version ",
+			err:          "failed to render instructions template: template: instructions:1: unclosed action",
+		},
+		{
+			name:         "invalid vars",
+			instructions: "Deploy {{ .Vars.version }}",
+			vars:         `["1.2.3"]`,
+			err:          "failed to render instructions template: instructionVars must be a JSON object: json: cannot unmarshal array into Go value of type map[string]interface {}",
+		},
+		{
+			name:         "invalid inputs",
+			instructions: "{{ .Inputs }}",
+			inputs:       "- in1\n- in2\n",
+			err:          "failed to render instructions template: invalid approvalInputs: expected a mapping of input names to definitions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+
+			// Run
+			result, err := renderInstructionsTemplate(tt.instructions, tt.approvers, tt.inputs, tt.vars)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, result)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_loadInstructions(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "RUNBOOK.md"), []byte(instructionsInput), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "HUGE.md"), []byte(strings.Repeat("a", maxInstructionsSize+1)), 0644))
+
+	tests := []struct {
+		name            string
+		instructions    string
+		file            string
+		instructionsUrl string
+		respGenFunc     func() (*http.Response, error)
+		output          string
+		err             string
+	}{
+		{
+			name:         "inline",
+			instructions: instructionsInput,
+			output:       instructionsInput,
+		},
+		{
+			name:   "file",
+			file:   "RUNBOOK.md",
+			output: instructionsInput,
+		},
+		{
+			name: "file outside of the workspace",
+			file: "../RUNBOOK.md",
+			err:  "failed to read instructions file: " + filepath.Join(workspace, "../RUNBOOK.md") + " is outside of the workspace " + workspace,
+		},
+		{
+			name: "file too large",
+			file: "HUGE.md",
+			err:  "failed to read instructions file: " + filepath.Join(workspace, "HUGE.md") + " exceeds the maximum size of 262144 bytes",
+		},
+		{
+			name:            "url",
+			instructionsUrl: "http://test.com/RUNBOOK.md",
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(instructionsInput)),
+				}, nil
+			},
+			output: instructionsInput,
+		},
+		{
+			name:            "url not found",
+			instructionsUrl: "http://test.com/RUNBOOK.md",
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString("not found")),
+				}, nil
+			},
+			err: "failed to download instructions: failed to download: \nGET http://test.com/RUNBOOK.md\nHTTP/404 404 Not Found\n",
+		},
+		{
+			name:            "url with unsupported scheme",
+			instructionsUrl: "file:///etc/passwd",
+			err:             "failed to download instructions: unsupported URL scheme 'file', only http and https are allowed",
+		},
+		{
+			name:         "multiple sources",
+			instructions: instructionsInput,
+			file:         "RUNBOOK.md",
+			err:          "only one of instructions, instructionsFile or instructionsUrl can be set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("CLOUDBEES_WORKSPACE", workspace)
+			defer os.Unsetenv("CLOUDBEES_WORKSPACE")
+
+			// Run
+			c := Config{
+				Client: &MockHttpClient{
+					MockDo: func(req *http.Request) (*http.Response, error) {
+						require.Equal(t, "GET", req.Method)
+						require.Equal(t, tt.instructionsUrl, req.URL.String())
+						return tt.respGenFunc()
+					},
+				},
+			}
+			result, err := c.loadInstructions(tt.instructions, tt.file, tt.instructionsUrl)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, result)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_sanitizeHtml(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			name:   "safe markdown",
+			input:  instructionsInput,
+			output: instructionsOutput,
+		},
+		{
+			name:   "script",
+			input:  "Deploy\n\n<script>alert('x')</script>",
+			output: "<p>Deploy</p>\n\n",
+		},
+		{
+			name:   "javascript link",
+			input:  "[click](javascript:alert('x'))",
+			output: "<p>click</p>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			result := sanitizeHtml(markdown(tt.input))
+
+			// Verify
+			require.Equal(t, tt.output, result)
+		})
+	}
+}
+
+func Test_checklistItems(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output []string
+	}{
+		{
+			name:   "no task list",
+			input:  instructionsInput,
+			output: nil,
+		},
+		{
+			name:   "unchecked items only",
+			input:  "Before approving:\n\n- [ ] I have verified the **rollback** plan\n- [x] Release notes are published\n- [ ] Monitoring is in place\n",
+			output: []string{"I have verified the rollback plan", "Monitoring is in place"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			result := checklistItems(tt.input)
+
+			// Verify
+			require.Equal(t, tt.output, result)
+		})
+	}
+}
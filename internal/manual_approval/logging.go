@@ -0,0 +1,213 @@
+package manual_approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Supported formats of the diagnostic logs
+const (
+	logOutputText = "text"
+	logOutputJson = "json"
+)
+
+// Keys whose values are always masked in the logs, compared case-insensitively.
+// The values of the environment variables with these names are masked wherever they appear.
+var defaultRedactKeys = []string{"token", "authorization", "API_TOKEN", "CALLBACK_TOKEN"}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// Logger of the diagnostic messages. Run routes it through the StdOut of the configuration.
+var logger = slog.New(newRedactingHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: debugLevel{}}), nil))
+
+// Sensitive values masked wherever they appear in the logs, such as secret input values
+var redacted = struct {
+	sync.Mutex
+	values []string
+}{}
+
+// The log level follows the debug flag unless a level is configured
+type debugLevel struct{}
+
+func (debugLevel) Level() slog.Level {
+	if debug {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// Create a logger writing to the StdOut in the given format and level, masking the given keys
+// in addition to the default ones
+func newLogger(out StdOut, format string, level string, redactKeys string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: debugLevel{}}
+	if level != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("unsupported log level '%s', valid values are: debug, info, warn, error", level)
+		}
+		opts.Level = l
+	}
+
+	w := stdOutWriter{out: out}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", logOutputText:
+		handler = slog.NewTextHandler(w, opts)
+	case logOutputJson:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unsupported log format '%s', valid values are: %s, %s", format, logOutputText, logOutputJson)
+	}
+	return slog.New(newRedactingHandler(handler, parseList(redactKeys))), nil
+}
+
+// Mask a sensitive value wherever it appears in the logs
+func redactValue(value string) {
+	if value == "" {
+		return
+	}
+	redacted.Lock()
+	defer redacted.Unlock()
+	if !slices.Contains(redacted.values, value) {
+		redacted.values = append(redacted.values, value)
+	}
+}
+
+type stdOutWriter struct {
+	out StdOut
+}
+
+func (w stdOutWriter) Write(p []byte) (int, error) {
+	w.out.Printf("%s", p)
+	return len(p), nil
+}
+
+// redactingHandler masks the values of sensitive keys, the registered sensitive values and
+// emails before passing the records on to the wrapped handler
+type redactingHandler struct {
+	slog.Handler
+	keys map[string]bool
+}
+
+func newRedactingHandler(handler slog.Handler, keys []string) *redactingHandler {
+	h := &redactingHandler{Handler: handler, keys: make(map[string]bool)}
+	for _, key := range append(slices.Clone(defaultRedactKeys), keys...) {
+		h.keys[strings.ToLower(key)] = true
+		redactValue(os.Getenv(key))
+	}
+	return h
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, h.redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		record.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = h.redactAttr(a)
+	}
+	return &redactingHandler{Handler: h.Handler.WithAttrs(redactedAttrs), keys: h.keys}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{Handler: h.Handler.WithGroup(name), keys: h.keys}
+}
+
+func (h *redactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	if h.keys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, secretMask)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactString(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]any, len(group))
+		for i, ga := range group {
+			attrs[i] = h.redactAttr(ga)
+		}
+		return slog.Group(a.Key, attrs...)
+	case slog.KindAny:
+		return slog.Any(a.Key, h.redactAny(v.Any()))
+	default:
+		return a
+	}
+}
+
+// Redact the sensitive keys and values of decoded JSON documents. Any other value is redacted
+// as its string representation or its JSON document, so nothing is logged unredacted.
+func (h *redactingHandler) redactAny(value any) any {
+	switch v := value.(type) {
+	case nil, bool, int, int64, float64, json.Number:
+		return value
+	case string:
+		return h.redactString(v)
+	case []byte:
+		return h.redactString(string(v))
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if h.keys[strings.ToLower(key)] {
+				result[key] = secretMask
+			} else {
+				result[key] = h.redactAny(item)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = h.redactAny(item)
+		}
+		return result
+	case error:
+		return h.redactString(v.Error())
+	case fmt.Stringer:
+		return h.redactString(v.String())
+	default:
+		// other values are redacted as the JSON document they are logged as, or as text
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			result := make([]interface{}, rv.Len())
+			for i := range result {
+				result[i] = h.redactAny(rv.Index(i).Interface())
+			}
+			return result
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return h.redactString(fmt.Sprint(value))
+		}
+		var decoded interface{}
+		if err := decodeJSON(string(data), &decoded); err != nil {
+			return h.redactString(fmt.Sprint(value))
+		}
+		return h.redactAny(decoded)
+	}
+}
+
+func (h *redactingHandler) redactString(s string) string {
+	redacted.Lock()
+	values := slices.Clone(redacted.values)
+	redacted.Unlock()
+
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, secretMask)
+	}
+	return emailPattern.ReplaceAllString(s, secretMask)
+}
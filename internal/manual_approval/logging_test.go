@@ -0,0 +1,131 @@
+package manual_approval
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		level      string
+		redactKeys string
+		env        map[string]string
+		log        func()
+		output     []string
+		err        string
+	}{
+		{
+			name:   "json with tokens redacted",
+			format: "json",
+			level:  "debug",
+			env:    map[string]string{"API_TOKEN": "api-token-value", "CALLBACK_TOKEN": "callback-token-value"},
+			log: func() {
+				logger.Debug("Request", "url", "http://example.com", "body", map[string]interface{}{"token": "callback-token-value", "approvers": []interface{}{"123"}})
+				logger.Info("Authorization: Bearer api-token-value")
+			},
+			output: []string{
+				`"level":"DEBUG","msg":"Request","url":"http://example.com","body":{"approvers":["123"],"token":"********"}}`,
+				`"level":"INFO","msg":"Authorization: Bearer ********"}`,
+			},
+		},
+		{
+			name:   "emails and secret values",
+			format: "text",
+			level:  "debug",
+			log: func() {
+				redactValue("123456")
+				logger.Debug("Approver", "email", "User.Name@mail.com", "inputs", []interface{}{map[string]interface{}{"name": "otp", "value": "123456"}})
+			},
+			output: []string{
+				`level=DEBUG msg=Approver email=******** inputs="[map[name:otp value:********]]"`,
+			},
+		},
+		{
+			name:   "approvers and other values",
+			format: "text",
+			level:  "debug",
+			log: func() {
+				approvers, err := parseApprovers("user:alice@example.com,team:ops,user:ops")
+				require.NoError(t, err)
+				logger.Debug("Approvers", "approvers", approvers, "names", approverNames(approvers))
+				logger.Debug("Approver", "approver", approvers[0])
+				logger.Debug("State", "state", &approvalState{Version: 1, ApprovalId: "1234", Approvers: approverNames(approvers)})
+			},
+			output: []string{
+				`level=DEBUG msg=Approvers approvers="[user:******** team:ops user:ops]" names="[user:******** team:ops user:ops]"`,
+				`level=DEBUG msg=Approver approver=user:********`,
+				`level=DEBUG msg=State state="map[approvalId:1234 approvers:[user:******** team:ops user:ops] requestedOn:0001-01-01T00:00:00Z version:1]"`,
+			},
+		},
+		{
+			name:       "configured keys",
+			format:     "text",
+			level:      "info",
+			redactKeys: "comments, DEPLOY_KEY",
+			env:        map[string]string{"DEPLOY_KEY": "deploy-key-value"},
+			log: func() {
+				logger.Debug("not logged")
+				logger.Info("Response", "comments", "lgtm", "key", "deploy-key-value")
+			},
+			output: []string{
+				`level=INFO msg=Response comments=******** key=********`,
+			},
+		},
+		{
+			name:   "unsupported format",
+			format: "xml",
+			err:    "unsupported log format 'xml', valid values are: text, json",
+		},
+		{
+			name:  "unsupported level",
+			level: "verbose",
+			err:   "unsupported log level 'verbose', valid values are: debug, info, warn, error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+			defer func(l *slog.Logger) {
+				logger = l
+			}(logger)
+
+			var testOutput []string
+			out := &MockStdOut{
+				MockPrintf: func(format string, a ...any) {
+					testOutput = append(testOutput, fmt.Sprintf(format, a...))
+				},
+			}
+
+			// Run
+			l, err := newLogger(out, tt.format, tt.level, tt.redactKeys)
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			logger = l
+			tt.log()
+
+			require.Len(t, testOutput, len(tt.output))
+			for i, line := range testOutput {
+				require.True(t, strings.HasSuffix(strings.TrimSuffix(line, "\n"), tt.output[i]), line)
+			}
+		})
+	}
+}
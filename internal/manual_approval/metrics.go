@@ -0,0 +1,277 @@
+package manual_approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Supported metrics exporters
+const (
+	metricsExporterNone       = "none"
+	metricsExporterPrometheus = "prometheus"
+	metricsExporterOtlp       = "otlp"
+)
+
+// Outcomes of an approval request
+const (
+	outcomeApproved = "approved"
+	outcomeRejected = "rejected"
+	outcomeAborted  = "aborted"
+	outcomeTimedOut = "timed_out"
+)
+
+var metricLabelName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Current time, replaced in tests
+var now = time.Now
+
+// Name of the output with the time from the approval request to the decision in seconds
+const timeToDecisionOutput = "timeToDecision"
+
+// approvalDecision is the outcome of an approval request and the time it took to decide on it
+type approvalDecision struct {
+	outcome  string
+	duration time.Duration
+}
+
+// Compute the time from the approval request to the decision
+func decisionOf(outcome string, requestedOn time.Time, decidedOn time.Time) *approvalDecision {
+	duration := decidedOn.Sub(requestedOn)
+	if duration < 0 {
+		duration = 0
+	}
+	return &approvalDecision{outcome: outcome, duration: duration}
+}
+
+// Write the time to decision to the outputs and export the metrics of the decision.
+// The request time is unknown for approvals requested without an approval state.
+func (k *Config) recordDecision(state *approvalState, outcome string, decidedOn time.Time) error {
+	if state == nil || state.RequestedOn.IsZero() {
+		logger.Debug("Approval metrics are not recorded as the request time is unknown", "outcome", outcome)
+		return nil
+	}
+	decision := decisionOf(outcome, state.RequestedOn, decidedOn)
+	logger.Debug("Approval decision", "outcome", decision.outcome, "duration", decision.duration)
+
+	seconds := strconv.FormatInt(int64(decision.duration.Round(time.Second)/time.Second), 10)
+	err := k.writeAsOutput(timeToDecisionOutput, []byte(seconds))
+	if err != nil {
+		return err
+	}
+
+	labels, err := parseMetricsLabels(os.Getenv("METRICS_LABELS"))
+	if err != nil {
+		return err
+	}
+
+	switch exporter := strings.ToLower(os.Getenv("METRICS_EXPORTER")); exporter {
+	case "", metricsExporterNone:
+		return nil
+	case metricsExporterPrometheus:
+		err = k.writePrometheusMetrics(os.Getenv("METRICS_FILE"), decision, labels)
+	case metricsExporterOtlp:
+		err = exportOtlpMetrics(k.ctx(), decision, labels)
+	default:
+		return fmt.Errorf("unsupported metrics exporter '%s', valid values are: %s, %s, %s", exporter, metricsExporterNone, metricsExporterPrometheus, metricsExporterOtlp)
+	}
+	if err != nil {
+		// the approval is decided, so failing to export its metrics does not fail the job
+		k.Output.Printf("WARNING: Failed to export approval metrics: %s\n", err)
+	}
+	return nil
+}
+
+// Parse the comma or newline separated name=value labels added to the metrics
+func parseMetricsLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, entry := range parseList(value) {
+		name, labelValue, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !metricLabelName.MatchString(name) {
+			return nil, fmt.Errorf("invalid metrics label '%s', expected name=value", entry)
+		}
+		if name == "outcome" {
+			return nil, fmt.Errorf("invalid metrics label '%s', the name is reserved", entry)
+		}
+		labels[name] = strings.TrimSpace(labelValue)
+	}
+	return labels, nil
+}
+
+// Names of the metrics written for the node exporter textfile collector
+const (
+	durationMetric  = "manual_approval_decision_duration_seconds"
+	decisionsMetric = "manual_approval_decisions_total"
+)
+
+// Write the metrics in the Prometheus text format for the node exporter textfile collector.
+// The metrics of earlier decisions are read from the file, so that the decisions are counted
+// per label set across the runs writing the file, and the duration is the one of the latest
+// decision. The file is replaced atomically, so that the collector never reads a partial file.
+func (k *Config) writePrometheusMetrics(path string, decision *approvalDecision, labels map[string]string) error {
+	if path == "" {
+		return fmt.Errorf("METRICS_FILE environment variable missing")
+	}
+
+	names := make([]string, 0, len(labels)+1)
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := []string{fmt.Sprintf("outcome=%s", strconv.Quote(decision.outcome))}
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(labels[name])))
+	}
+	selector := "{" + strings.Join(pairs, ",") + "}"
+
+	samples, err := readPrometheusMetrics(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the metrics file %s: %w", path, err)
+	}
+	samples.set(durationMetric, selector, decision.duration.Seconds())
+	samples.add(decisionsMetric, selector, 1)
+
+	var b strings.Builder
+	b.WriteString("# HELP " + durationMetric + " Time from the approval request to the latest decision.\n")
+	b.WriteString("# TYPE " + durationMetric + " gauge\n")
+	samples.write(&b, durationMetric)
+	b.WriteString("# HELP " + decisionsMetric + " Number of decided approval requests.\n")
+	b.WriteString("# TYPE " + decisionsMetric + " counter\n")
+	samples.write(&b, decisionsMetric)
+
+	if k.DryRun {
+		k.Output.Printf("DRY RUN: metrics '%s':\n%s", path, b.String())
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// The samples of the metrics in a textfile, by metric name and label selector in the order they were written
+type prometheusSamples struct {
+	selectors map[string][]string
+	values    map[string]float64
+}
+
+// Read the samples of the metrics written to a textfile by earlier runs. A missing file has no samples,
+// and the samples of other metrics and lines which are not samples are dropped.
+func readPrometheusMetrics(path string) (*prometheusSamples, error) {
+	samples := &prometheusSamples{selectors: make(map[string][]string), values: make(map[string]float64)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return samples, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		if i < 0 {
+			logger.Debug("Dropping an invalid line of the metrics file", "path", path, "line", n+1)
+			continue
+		}
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			logger.Debug("Dropping an invalid line of the metrics file", "path", path, "line", n+1)
+			continue
+		}
+		for _, metric := range []string{durationMetric, decisionsMetric} {
+			if selector, ok := strings.CutPrefix(line[:i], metric); ok && strings.HasPrefix(selector, "{") {
+				samples.set(metric, selector, value)
+			}
+		}
+	}
+	return samples, nil
+}
+
+func (s *prometheusSamples) set(metric string, selector string, value float64) {
+	key := metric + selector
+	if _, ok := s.values[key]; !ok {
+		s.selectors[metric] = append(s.selectors[metric], selector)
+	}
+	s.values[key] = value
+}
+
+func (s *prometheusSamples) add(metric string, selector string, value float64) {
+	s.set(metric, selector, s.values[metric+selector]+value)
+}
+
+func (s *prometheusSamples) write(b *strings.Builder, metric string) {
+	for _, selector := range s.selectors[metric] {
+		b.WriteString(metric + selector + " " + strconv.FormatFloat(s.values[metric+selector], 'f', -1, 64) + "\n")
+	}
+}
+
+// Export the metrics with OTLP/HTTP, configured with the standard OTEL_EXPORTER_OTLP_* variables
+func exportOtlpMetrics(ctx context.Context, decision *approvalDecision, labels map[string]string) error {
+	exporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return err
+	}
+	return exportMetrics(ctx, exporter, decision, labels)
+}
+
+func exportMetrics(ctx context.Context, exporter sdkmetric.Exporter, decision *approvalDecision, labels map[string]string) error {
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+		sdkmetric.WithResource(resource.NewSchemaless(attribute.String("service.name", "manual-approval"))),
+	)
+
+	attrs := []attribute.KeyValue{attribute.String("outcome", decision.outcome)}
+	for name, value := range labels {
+		attrs = append(attrs, attribute.String(name, value))
+	}
+	set := metric.WithAttributes(attrs...)
+
+	meter := provider.Meter(tracerName)
+	duration, err := meter.Float64Histogram("manual_approval.decision.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time from the approval request to the decision."))
+	if err != nil {
+		return err
+	}
+	decisions, err := meter.Int64Counter("manual_approval.decisions",
+		metric.WithDescription("Number of decided approval requests."))
+	if err != nil {
+		return err
+	}
+	duration.Record(ctx, decision.duration.Seconds(), set)
+	decisions.Add(ctx, 1, set)
+
+	// shutting down the provider flushes the metrics to the exporter
+	return provider.Shutdown(ctx)
+}
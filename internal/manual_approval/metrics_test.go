@@ -0,0 +1,191 @@
+package manual_approval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func Test_decisionOf(t *testing.T) {
+	decidedOn := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		requestedOn time.Time
+		duration    time.Duration
+	}{
+		{
+			name:        "decided",
+			requestedOn: time.Date(2009, 11, 10, 21, 30, 0, 0, time.UTC),
+			duration:    90 * time.Minute,
+		},
+		{
+			name:        "request time after the decision",
+			requestedOn: time.Date(2009, 11, 10, 23, 0, 5, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := decisionOf(outcomeApproved, tt.requestedOn, decidedOn)
+			require.Equal(t, &approvalDecision{outcome: outcomeApproved, duration: tt.duration}, decision)
+		})
+	}
+}
+
+func Test_parseMetricsLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels string
+		want   map[string]string
+		err    string
+	}{
+		{
+			name:   "no labels",
+			labels: "",
+			want:   map[string]string{},
+		},
+		{
+			name:   "labels",
+			labels: "workflow=deploy, environment = production\nteam=",
+			want:   map[string]string{"workflow": "deploy", "environment": "production", "team": ""},
+		},
+		{
+			name:   "missing value",
+			labels: "workflow",
+			err:    "invalid metrics label 'workflow', expected name=value",
+		},
+		{
+			name:   "invalid name",
+			labels: "work-flow=deploy",
+			err:    "invalid metrics label 'work-flow=deploy', expected name=value",
+		},
+		{
+			name:   "reserved name",
+			labels: "outcome=approved",
+			err:    "invalid metrics label 'outcome=approved', the name is reserved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, err := parseMetricsLabels(tt.labels)
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, labels)
+		})
+	}
+}
+
+func Test_writePrometheusMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manual-approval.prom")
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0644))
+
+	c := Config{}
+	decision := &approvalDecision{outcome: outcomeTimedOut, duration: 4320 * time.Minute}
+	err := c.writePrometheusMetrics(path, decision, map[string]string{"workflow": "deploy", "environment": "prod \"eu\""})
+
+	require.NoError(t, err)
+	out, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# HELP manual_approval_decision_duration_seconds Time from the approval request to the latest decision.\n"+
+		"# TYPE manual_approval_decision_duration_seconds gauge\n"+
+		"manual_approval_decision_duration_seconds{outcome=\"timed_out\",environment=\"prod \\\"eu\\\"\",workflow=\"deploy\"} 259200\n"+
+		"# HELP manual_approval_decisions_total Number of decided approval requests.\n"+
+		"# TYPE manual_approval_decisions_total counter\n"+
+		"manual_approval_decisions_total{outcome=\"timed_out\",environment=\"prod \\\"eu\\\"\",workflow=\"deploy\"} 1\n", string(out))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func Test_writePrometheusMetricsCountsDecisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manual-approval.prom")
+
+	c := Config{}
+	labels := map[string]string{"workflow": "deploy"}
+	for _, decision := range []*approvalDecision{
+		{outcome: outcomeApproved, duration: 30 * time.Minute},
+		{outcome: outcomeRejected, duration: 10 * time.Minute},
+		{outcome: outcomeApproved, duration: 90 * time.Second},
+	} {
+		require.NoError(t, c.writePrometheusMetrics(path, decision, labels))
+	}
+	require.NoError(t, c.writePrometheusMetrics(path, &approvalDecision{outcome: outcomeApproved, duration: time.Minute}, map[string]string{"workflow": "release"}))
+
+	out, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# HELP manual_approval_decision_duration_seconds Time from the approval request to the latest decision.\n"+
+		"# TYPE manual_approval_decision_duration_seconds gauge\n"+
+		"manual_approval_decision_duration_seconds{outcome=\"approved\",workflow=\"deploy\"} 90\n"+
+		"manual_approval_decision_duration_seconds{outcome=\"rejected\",workflow=\"deploy\"} 600\n"+
+		"manual_approval_decision_duration_seconds{outcome=\"approved\",workflow=\"release\"} 60\n"+
+		"# HELP manual_approval_decisions_total Number of decided approval requests.\n"+
+		"# TYPE manual_approval_decisions_total counter\n"+
+		"manual_approval_decisions_total{outcome=\"approved\",workflow=\"deploy\"} 2\n"+
+		"manual_approval_decisions_total{outcome=\"rejected\",workflow=\"deploy\"} 1\n"+
+		"manual_approval_decisions_total{outcome=\"approved\",workflow=\"release\"} 1\n", string(out))
+}
+
+// Exporter keeping the exported metrics in memory
+type mockMetricsExporter struct {
+	sdkmetric.Exporter
+	metrics []metricdata.ResourceMetrics
+}
+
+func (e *mockMetricsExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (e *mockMetricsExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *mockMetricsExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.metrics = append(e.metrics, *rm)
+	return nil
+}
+
+func (e *mockMetricsExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *mockMetricsExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func Test_exportMetrics(t *testing.T) {
+	exporter := &mockMetricsExporter{}
+	decision := &approvalDecision{outcome: outcomeRejected, duration: 90 * time.Second}
+
+	err := exportMetrics(context.Background(), exporter, decision, map[string]string{"workflow": "deploy"})
+
+	require.NoError(t, err)
+	require.Len(t, exporter.metrics, 1)
+	require.Len(t, exporter.metrics[0].ScopeMetrics, 1)
+	metrics := exporter.metrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 2)
+	attrs := attribute.NewSet(attribute.String("outcome", "rejected"), attribute.String("workflow", "deploy"))
+
+	require.Equal(t, "manual_approval.decision.duration", metrics[0].Name)
+	require.Equal(t, "s", metrics[0].Unit)
+	histogram := metrics[0].Data.(metricdata.Histogram[float64])
+	require.Len(t, histogram.DataPoints, 1)
+	require.Equal(t, attrs, histogram.DataPoints[0].Attributes)
+	require.Equal(t, 90.0, histogram.DataPoints[0].Sum)
+
+	require.Equal(t, "manual_approval.decisions", metrics[1].Name)
+	counter := metrics[1].Data.(metricdata.Sum[int64])
+	require.Len(t, counter.DataPoints, 1)
+	require.Equal(t, attrs, counter.DataPoints[0].Attributes)
+	require.Equal(t, int64(1), counter.DataPoints[0].Value)
+}
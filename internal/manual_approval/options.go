@@ -0,0 +1,105 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Maximum size of an optionsFrom document
+const maxOptionsSize = 1024 * 1024
+
+// Maximum number of options resolved from an optionsFrom document
+const maxOptions = 1000
+
+// Replace the optionsFrom of choice and multichoice inputs with the options it resolves to
+func (k *Config) resolveInputOptions(inputs string) (string, error) {
+	root, err := parseInputsNode(inputs)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := false
+	for i := 0; i < len(root.Content); i += 2 {
+		name, def := root.Content[i].Value, root.Content[i+1]
+		if def.Kind != yaml.MappingNode {
+			continue
+		}
+
+		for j := 0; j < len(def.Content); j += 2 {
+			if def.Content[j].Value != "optionsFrom" {
+				continue
+			}
+
+			source := def.Content[j+1].Value
+			options, err := k.loadOptions(source)
+			if err != nil {
+				return "", fmt.Errorf("input '%s': failed to resolve optionsFrom '%s': %w", name, source, err)
+			}
+			logger.Debug("Resolved options", "input", name, "source", source, "count", len(options))
+
+			value := &yaml.Node{}
+			if err := value.Encode(options); err != nil {
+				return "", err
+			}
+			def.Content[j].Value = "options"
+			def.Content[j+1] = value
+			resolved = true
+			break
+		}
+	}
+
+	if !resolved {
+		return inputs, nil
+	}
+
+	resolvedInputs, err := marshalInputs(root)
+	if err != nil {
+		return "", err
+	}
+	// the when conditions referring to the resolved inputs can only be validated now
+	if _, err := parseInputs(resolvedInputs); err != nil {
+		return "", err
+	}
+	return resolvedInputs, nil
+}
+
+// Load the options from a JSON array of strings in a workspace file or at a URL
+func (k *Config) loadOptions(source string) ([]string, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = k.download(source, maxOptionsSize)
+	} else {
+		data, err = readWorkspaceFile(source, maxOptionsSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var options []string
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of strings: %w", err)
+	}
+
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no options found")
+	}
+	if len(options) > maxOptions {
+		return nil, fmt.Errorf("found %d options, the maximum is %d", len(options), maxOptions)
+	}
+
+	seen := make(map[string]bool)
+	for _, option := range options {
+		if strings.TrimSpace(option) == "" {
+			return nil, fmt.Errorf("options must not be empty")
+		}
+		if seen[option] {
+			return nil, fmt.Errorf("duplicate option '%s'", option)
+		}
+		seen[option] = true
+	}
+	return options, nil
+}
@@ -0,0 +1,125 @@
+package manual_approval
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveInputOptions(t *testing.T) {
+	workspace := t.TempDir()
+	files := map[string]string{
+		"environments.json": `["staging", "production"]`,
+		"empty.json":        `[]`,
+		"duplicates.json":   `["staging", "staging"]`,
+		"object.json":       `{"staging": true}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, name), []byte(content), 0644))
+	}
+
+	tests := []struct {
+		name        string
+		inputs      string
+		respGenFunc func() (*http.Response, error)
+		output      string
+		err         string
+	}{
+		{
+			name:   "no optionsFrom",
+			inputs: approvalInputs,
+			output: approvalInputs,
+		},
+		{
+			name:   "file",
+			inputs: "in1:\n  type: string\ntarget:\n  type: multichoice\n  description: Target environments\n  optionsFrom: environments.json\n",
+			output: "in1:\n  type: string\ntarget:\n  type: multichoice\n  description: Target environments\n  options:\n    - staging\n    - production\n",
+		},
+		{
+			name:   "condition on the resolved options",
+			inputs: "env:\n  type: choice\n  optionsFrom: environments.json\ntarget:\n  type: string\n  when:\n    env: production\n",
+			output: "env:\n  type: choice\n  options:\n    - staging\n    - production\ntarget:\n  type: string\n  when:\n    env: production\n",
+		},
+		{
+			name:   "condition value not in the resolved options",
+			inputs: "env:\n  type: choice\n  optionsFrom: environments.json\ntarget:\n  type: string\n  when:\n    env: prod\n",
+			err:    "input 'target': invalid when condition: input 'env': value must be one of: staging, production",
+		},
+		{
+			name:   "url",
+			inputs: "target:\n  type: choice\n  optionsFrom: https://test.com/environments\n",
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`["eu", "us"]`)),
+				}, nil
+			},
+			output: "target:\n  type: choice\n  options:\n    - eu\n    - us\n",
+		},
+		{
+			name:   "url not found",
+			inputs: "target:\n  type: choice\n  optionsFrom: https://test.com/environments\n",
+			respGenFunc: func() (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString("not found")),
+				}, nil
+			},
+			err: "input 'target': failed to resolve optionsFrom 'https://test.com/environments': failed to download: \nGET https://test.com/environments\nHTTP/404 404 Not Found\n",
+		},
+		{
+			name:   "missing file",
+			inputs: "target:\n  type: choice\n  optionsFrom: missing.json\n",
+			err:    "input 'target': failed to resolve optionsFrom 'missing.json': open " + filepath.Join(workspace, "missing.json") + ": no such file or directory",
+		},
+		{
+			name:   "no options",
+			inputs: "target:\n  type: choice\n  optionsFrom: empty.json\n",
+			err:    "input 'target': failed to resolve optionsFrom 'empty.json': no options found",
+		},
+		{
+			name:   "duplicate options",
+			inputs: "target:\n  type: choice\n  optionsFrom: duplicates.json\n",
+			err:    "input 'target': failed to resolve optionsFrom 'duplicates.json': duplicate option 'staging'",
+		},
+		{
+			name:   "not an array",
+			inputs: "target:\n  type: choice\n  optionsFrom: object.json\n",
+			err:    "input 'target': failed to resolve optionsFrom 'object.json': expected a JSON array of strings: json: cannot unmarshal object into Go value of type []string",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("CLOUDBEES_WORKSPACE", workspace)
+			defer os.Unsetenv("CLOUDBEES_WORKSPACE")
+
+			// Run
+			c := Config{
+				Client: &MockHttpClient{
+					MockDo: func(req *http.Request) (*http.Response, error) {
+						require.Equal(t, "GET", req.Method)
+						return tt.respGenFunc()
+					},
+				},
+			}
+			result, err := c.resolveInputOptions(tt.inputs)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, result)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
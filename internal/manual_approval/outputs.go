@@ -0,0 +1,190 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Maximum size of a single output value accepted by the platform
+const maxOutputSize = 64 * 1024
+
+// Name of the output with all input values in dotenv format
+const inputsEnvOutput = "approvalInputsEnv"
+
+// Name of the file in the state directory with all input values in dotenv format
+const inputsEnvFileName = "manual-approval-inputs.env"
+
+// Outputs written by the handlers, which must not be overwritten by per-input outputs.
+// The reasonCode output is not reserved, as it is the value of the reasonCode input.
+var reservedOutputs = map[string]bool{
+	"approvalInputValues": true,
+	"comments":            true,
+	inputsEnvOutput:       true,
+	timeToDecisionOutput:  true,
+	stateOutput:           true,
+	summaryOutput:         true,
+	summaryHtmlOutput:     true,
+}
+
+var invalidOutputChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Get an output name, which is usable in expressions and as an environment variable name
+func outputName(prefix string, name string) string {
+	sanitized := invalidOutputChars.ReplaceAllString(prefix+name, "_")
+	if sanitized == "" || (sanitized[0] >= '0' && sanitized[0] <= '9') {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
+
+// Get the path of the dotenv file in the state directory, which is empty if no state directory is configured
+func inputsEnvFile() (string, error) {
+	dir := os.Getenv("STATE_DIR")
+	if dir == "" {
+		return "", nil
+	}
+	dir, err := workspacePath(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, inputsEnvFileName), nil
+}
+
+// Check that the outputs holding input values do not exceed the maximum output size, unless the
+// input values are written to the dotenv file. It is checked before the decision is recorded, as
+// the outputs cannot be written afterwards.
+func checkInputOutputSizes(outputsMap map[string]interface{}, prefix string, envFile string) error {
+	if envFile != "" || len(outputsMap) == 0 {
+		return nil
+	}
+
+	values, err := json.Marshal(outputsMap)
+	if err != nil {
+		return err
+	}
+	if len(values) > maxOutputSize {
+		return outputSizeError("approvalInputValues")
+	}
+
+	outputs, env, _, err := inputOutputs(outputsMap, prefix)
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		if len(output.value) > maxOutputSize {
+			return outputSizeError(output.name)
+		}
+	}
+	if len(env) > maxOutputSize {
+		return outputSizeError(inputsEnvOutput)
+	}
+	return nil
+}
+
+func outputSizeError(name string) error {
+	return fmt.Errorf("output '%s' exceeds the maximum output size of %d bytes, configure a state directory to write the input values to a file", name, maxOutputSize)
+}
+
+// Write an output holding input values. An output exceeding the maximum output size is skipped
+// if the input values are written to the dotenv file, and fails the job otherwise.
+func (k *Config) writeInputsOutput(name string, value []byte, envFile string) error {
+	if len(value) <= maxOutputSize {
+		return k.writeAsOutput(name, value)
+	}
+	if envFile == "" {
+		return outputSizeError(name)
+	}
+	k.Output.Printf("WARNING: Output '%s' exceeds the maximum output size of %d bytes, the input values are only available in the file '%s'\n", name, maxOutputSize, envFile)
+	return nil
+}
+
+// Write the input values in dotenv format to a file, which is shared with the later steps through the workspace
+func (k *Config) writeInputsEnvFile(path string, env []byte) error {
+	if k.DryRun {
+		k.Output.Printf("DRY RUN: file '%s':\n%s\n", path, env)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, env, 0644)
+}
+
+// An output holding the value of an input
+type inputOutput struct {
+	name  string
+	value string
+}
+
+// Get the outputs of the input values sorted by input name, and all of them in dotenv format.
+// The inputs whose output name is reserved or already used by another input are skipped, with
+// a warning for each of them.
+func inputOutputs(outputsMap map[string]interface{}, prefix string) ([]inputOutput, string, []string, error) {
+	names := make([]string, 0, len(outputsMap))
+	for name := range outputsMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var outputs []inputOutput
+	var env strings.Builder
+	var warnings []string
+	written := make(map[string]string)
+	for _, name := range names {
+		value, err := formatValue(outputsMap[name])
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		output := outputName(prefix, name)
+		if reservedOutputs[output] {
+			warnings = append(warnings, fmt.Sprintf("Input '%s' is not written to output '%s' as the name is reserved", name, output))
+			continue
+		}
+		if other, ok := written[output]; ok {
+			warnings = append(warnings, fmt.Sprintf("Input '%s' is not written to output '%s' as it is already used by input '%s'", name, output, other))
+			continue
+		}
+		written[output] = name
+
+		env.WriteString(output + "=" + shellQuote(value) + "\n")
+		outputs = append(outputs, inputOutput{name: output, value: value})
+	}
+	return outputs, env.String(), warnings, nil
+}
+
+// Write one output per input value and all input values as a dotenv output. With a state directory
+// the dotenv is also written to a file, which holds the values exceeding the maximum output size.
+func (k *Config) writeInputOutputs(outputsMap map[string]interface{}, prefix string, envFile string) error {
+	outputs, env, warnings, err := inputOutputs(outputsMap, prefix)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		k.Output.Printf("WARNING: %s\n", warning)
+	}
+
+	for _, output := range outputs {
+		err = k.writeInputsOutput(output.name, []byte(output.value), envFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if envFile != "" {
+		if err := k.writeInputsEnvFile(envFile, []byte(env)); err != nil {
+			return fmt.Errorf("failed to write the input values to %s: %w", envFile, err)
+		}
+	}
+	return k.writeInputsOutput(inputsEnvOutput, []byte(env), envFile)
+}
+
+// Quote a value for a dotenv file, which can be sourced by a shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,160 @@
+package manual_approval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_outputName(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		input  string
+		output string
+	}{
+		{name: "plain", input: "version", output: "version"},
+		{name: "dashes and spaces", input: "rollback target-env", output: "rollback_target_env"},
+		{name: "prefix", prefix: "approval-", input: "version", output: "approval_version"},
+		{name: "leading digit", input: "1st-choice", output: "_1st_choice"},
+		{name: "unicode", input: "größe", output: "gr__e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			result := outputName(tt.prefix, tt.input)
+
+			// Verify
+			require.Equal(t, tt.output, result)
+		})
+	}
+}
+
+func Test_shellQuote(t *testing.T) {
+	require.Equal(t, `'plain'`, shellQuote("plain"))
+	require.Equal(t, `'it'\''s $HOME'`, shellQuote("it's $HOME"))
+	require.Equal(t, "'multi\nline'", shellQuote("multi\nline"))
+}
+
+func Test_checkInputOutputSizes(t *testing.T) {
+	large := strings.Repeat("x", maxOutputSize/2)
+	quotes := strings.Repeat("'", maxOutputSize/4+1)
+	tests := []struct {
+		name    string
+		outputs map[string]interface{}
+		envFile string
+		err     string
+	}{
+		{
+			name:    "within the maximum size",
+			outputs: map[string]interface{}{"region": "eu", "notes": large},
+		},
+		{
+			name:    "all input values",
+			outputs: map[string]interface{}{"region": large, "notes": large},
+			err:     "output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file",
+		},
+		{
+			name:    "dotenv",
+			outputs: map[string]interface{}{"notes": quotes},
+			err:     "output 'approvalInputsEnv' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file",
+		},
+		{
+			name:    "with state directory",
+			outputs: map[string]interface{}{"region": large, "notes": large},
+			envFile: ".approval/manual-approval-inputs.env",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			err := checkInputOutputSizes(tt.outputs, "", tt.envFile)
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_writeToOutputsExceedingMaxSize(t *testing.T) {
+	large := strings.Repeat("x", maxOutputSize+1)
+	tests := []struct {
+		name     string
+		stateDir string
+		outputs  []string
+		output   []string
+		err      string
+	}{
+		{
+			name:    "without state directory",
+			outputs: []string{},
+			err:     "output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, configure a state directory to write the input values to a file",
+		},
+		{
+			name:     "with state directory",
+			stateDir: ".approval",
+			outputs:  []string{"comments", "region"},
+			output: []string{
+				"WARNING: Output 'approvalInputValues' exceeds the maximum output size of 65536 bytes, the input values are only available in the file 'WORKSPACE/.approval/manual-approval-inputs.env'\n",
+				"WARNING: Output 'notes' exceeds the maximum output size of 65536 bytes, the input values are only available in the file 'WORKSPACE/.approval/manual-approval-inputs.env'\n",
+				"WARNING: Output 'approvalInputsEnv' exceeds the maximum output size of 65536 bytes, the input values are only available in the file 'WORKSPACE/.approval/manual-approval-inputs.env'\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			workspace := t.TempDir()
+			outputs := t.TempDir()
+			os.Setenv("CLOUDBEES_WORKSPACE", workspace)
+			defer os.Unsetenv("CLOUDBEES_WORKSPACE")
+			os.Setenv("CLOUDBEES_OUTPUTS", outputs)
+			defer os.Unsetenv("CLOUDBEES_OUTPUTS")
+			if tt.stateDir != "" {
+				os.Setenv("STATE_DIR", tt.stateDir)
+				defer os.Unsetenv("STATE_DIR")
+			}
+
+			var testOutput []string
+			c := Config{
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, strings.ReplaceAll(fmt.Sprintf(format, a...), workspace, "WORKSPACE"))
+					},
+				},
+			}
+
+			// Run
+			err := c.writeToOutputs(map[string]interface{}{"region": "eu", "notes": large}, "LGTM")
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			} else {
+				require.NoError(t, err)
+				env, err := os.ReadFile(filepath.Join(workspace, tt.stateDir, inputsEnvFileName))
+				require.NoError(t, err)
+				require.Equal(t, "notes='"+large+"'\nregion='eu'\n", string(env))
+			}
+			require.Equal(t, tt.output, testOutput)
+
+			entries, err := os.ReadDir(outputs)
+			require.NoError(t, err)
+			names := []string{}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			require.Equal(t, tt.outputs, names)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse the window in which an earlier approval is reused, for example 12h or 7d.
+// An empty window disables the reuse.
+func parseReuseWindow(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	var window time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid reuseApprovalWithin value '%s'", value)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		window, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid reuseApprovalWithin value '%s'", value)
+		}
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("invalid reuseApprovalWithin value '%s', the window must be positive", value)
+	}
+	return window, nil
+}
+
+// Look up the latest approved decision with the reuse key within the window, which is nil if there is none.
+// The platform may not provide the lookup, which is returned as an error which isNotSupported.
+func (k *Config) reusableDecision(key string, window time.Duration) (*ApprovalDecision, error) {
+	since := now().Add(-window).UTC()
+	resp, err := k.request("POST", "/v1/workflows/approval/decisions", map[string]interface{}{
+		"reuseKey":       key,
+		"status":         "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+		"respondedAfter": since.Format(time.RFC3339),
+	})
+	if isNotSupported(err) {
+		return nil, fmt.Errorf("reuseApprovalWithin is ignored as the platform API does not support looking up earlier approvals: %w", err)
+	}
+	if err != nil {
+		k.Output.Printf("ERROR: API response: '%s'\n", resp)
+		return nil, fmt.Errorf("failed to look up earlier approvals: %w", err)
+	}
+	logger.Debug("Response", "response", resp)
+
+	parsedResp := ApprovalDecisionsResponse{}
+	if err := json.Unmarshal([]byte(resp), &parsedResp); err != nil {
+		return nil, fmt.Errorf("failed to look up earlier approvals: %w", err)
+	}
+
+	// only approvals within the window are reused, whatever the platform returns
+	var latest *ApprovalDecision
+	var latestOn time.Time
+	for i, decision := range parsedResp.Decisions {
+		respondedOn, err := time.Parse(time.RFC3339, decision.RespondedOn)
+		if err != nil || respondedOn.Before(since) {
+			logger.Debug("Earlier approval is not reused", "id", decision.Id, "respondedOn", decision.RespondedOn)
+			continue
+		}
+		if latest == nil || respondedOn.After(latestOn) {
+			latest = &parsedResp.Decisions[i]
+			latestOn = respondedOn
+		}
+	}
+	return latest, nil
+}
+
+// Check an earlier decision against the approval policies of the manual approval job, as its approver
+// responded to another approval request, possibly with other approvers, approvalInputs or policies
+func (k *Config) validateReusedDecision(decision *ApprovalDecision, inputs string, approvers []approver, groups []ownerGroup, disallowLaunchedByUser bool) error {
+	inputDefs, err := parseInputs(inputs)
+	if err != nil {
+		return fmt.Errorf("invalid approvalInputs: %w", err)
+	}
+	err = validateReusedApprover(decision, approvers, groups, disallowLaunchedByUser)
+	if err != nil {
+		return err
+	}
+	err = k.validateResponder(decision.UserId, decision.UserName, decision.Email)
+	if err != nil {
+		return err
+	}
+	return validateResponse("UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", decision.Comments, inputDefs, payloadInputValues(decision.payload()))
+}
+
+// Check that the approver of an earlier decision may approve this request. The approver has to be one
+// of the approvers, if any are requested, and one of the owners of each approver group. Members of
+// teams are not known to the job, so team approvers never match. The user who launched the workflow
+// is not known either, so no decision is reused if disallowLaunchByUser is set.
+func validateReusedApprover(decision *ApprovalDecision, approvers []approver, groups []ownerGroup, disallowLaunchedByUser bool) error {
+	if disallowLaunchedByUser {
+		return fmt.Errorf("approvals are not reused with disallowLaunchByUser, as the user who launched the workflow is not known")
+	}
+	if len(approvers) > 0 && !slices.ContainsFunc(approvers, decision.approvedBy) {
+		return fmt.Errorf("approver '%s' is not one of the requested approvers", decision.UserName)
+	}
+	for _, group := range groups {
+		if !slices.ContainsFunc(group.owners, decision.approvedBy) {
+			return fmt.Errorf("approver '%s' is not one of the owners of %s", decision.UserName, strings.Join(group.paths, ", "))
+		}
+	}
+	return nil
+}
+
+// Check whether the decision was made by a user approver, matching its user ID or email
+func (d ApprovalDecision) approvedBy(a approver) bool {
+	if a.kind != approverKindUser {
+		return false
+	}
+	return (d.UserId != "" && strings.EqualFold(a.name, d.UserId)) || (d.Email != "" && strings.EqualFold(a.name, d.Email))
+}
+
+// Approve with an earlier decision instead of requesting approval, carrying forward the approver,
+// comments and input values of the earlier decision. The decision is recorded like the decisions
+// of the callback handler, in the approval state of the request which is not sent.
+func (k *Config) reuseDecision(decision *ApprovalDecision, key string, state *approvalState) error {
+	inputDefs, err := parseInputs(state.Inputs)
+	if err != nil {
+		return fmt.Errorf("invalid approvalInputs: %w", err)
+	}
+
+	// by default no evidence is written
+	evidenceFormats, err := parseEvidenceFormats(os.Getenv("EVIDENCE_FORMAT"))
+	if err != nil {
+		return err
+	}
+
+	payload := decision.payload()
+	dropHiddenInputs(payload, inputDefs)
+	inputsForPost, outputsMap, err := formatInputsForPost(payload, secretInputs(inputDefs))
+	if err != nil {
+		return err
+	}
+
+	k.Output.Printf("Reusing the approval of the approval request %s with the reuse key '%s'\n", decision.displayName(), key)
+	k.Output.Printf("Approved by %s on %s with comments:\n%s\n", decision.UserName, decision.RespondedOn, decision.Comments)
+	k.formatInputsValsAndWriteToLog(inputsForPost)
+
+	err = k.writeToOutputs(outputsMap, decision.Comments)
+	if err != nil {
+		return err
+	}
+
+	// export the reason code picked by the approver
+	if reasonCode, ok := outputsMap[reasonCodeInput].(string); ok {
+		err = k.writeAsOutput("reasonCode", []byte(reasonCode))
+		if err != nil {
+			return err
+		}
+	}
+
+	state.ApprovalId, err = newApprovalId()
+	if err != nil {
+		return err
+	}
+	state.ReusedFrom = decision.Id
+	decidedOn := now()
+	err = k.recordDecision(state, outcomeApproved, decidedOn)
+	if err != nil {
+		return err
+	}
+	err = k.saveDecision(state, outcomeApproved, decidedOn)
+	if err != nil {
+		return err
+	}
+
+	// report the reused decision on the run details page
+	summary := newApprovalSummary(state, outcomeApproved, decidedOn)
+	summary.Approver = decision.UserName
+	summary.ReusedFrom = decision.displayName()
+	summary.Inputs = summaryInputs(inputsForPost)
+	summary.Comments = decision.Comments
+	err = k.writeSummary(summary)
+	if err != nil {
+		return err
+	}
+
+	// compliance evidence of the reused decision
+	err = k.writeEvidence(evidenceFormats, state, outcomeApproved, decidedOn, payload)
+	if err != nil {
+		return err
+	}
+
+	return k.writeStatus("APPROVED", "Reused an earlier approval of the workflow manual approval request")
+}
+
+// Get the earlier decision in the format of the approval response of the callback handler
+func (d ApprovalDecision) payload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"status":      "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+		"comments":    d.Comments,
+		"userName":    d.UserName,
+		"respondedOn": d.RespondedOn,
+	}
+	if d.UserId != "" {
+		payload["userId"] = d.UserId
+	}
+	if d.Email != "" {
+		payload["email"] = d.Email
+	}
+	if len(d.Inputs) > 0 {
+		payload["inputs"] = d.Inputs
+	}
+	return payload
+}
+
+func (d ApprovalDecision) displayName() string {
+	if d.Url != "" {
+		return fmt.Sprintf("%s (%s)", d.Id, d.Url)
+	}
+	return d.Id
+}
@@ -0,0 +1,451 @@
+package manual_approval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseReuseWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		window time.Duration
+		err    string
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:   "hours",
+			value:  "12h",
+			window: 12 * time.Hour,
+		},
+		{
+			name:   "days",
+			value:  " 7d ",
+			window: 7 * 24 * time.Hour,
+		},
+		{
+			name:  "invalid",
+			value: "a week",
+			err:   "invalid reuseApprovalWithin value 'a week'",
+		},
+		{
+			name:  "invalid days",
+			value: "1.5d",
+			err:   "invalid reuseApprovalWithin value '1.5d'",
+		},
+		{
+			name:  "negative",
+			value: "-1h",
+			err:   "invalid reuseApprovalWithin value '-1h', the window must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			window, err := parseReuseWindow(tt.value)
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.window, window)
+		})
+	}
+}
+
+func Test_reusableDecision(t *testing.T) {
+	tests := []struct {
+		name        string
+		respGenFunc func(req map[string]interface{}) (*http.Response, error)
+		decision    *ApprovalDecision
+		err         string
+	}{
+		{
+			name: "latest approval within the window",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				require.Equal(t, "sha256:1234", req["reuseKey"])
+				require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED", req["status"])
+				require.Equal(t, "2009-11-09T23:30:00Z", req["respondedAfter"])
+				return okResponse(`{"decisions":[
+					{"id":"1111","userName":"jdoe","comments":"LGTM","respondedOn":"2009-11-10T08:00:00Z"},
+					{"id":"2222","userName":"asmith","comments":"Ship it","respondedOn":"2009-11-10T20:00:00Z"},
+					{"id":"3333","userName":"bwayne","comments":"Too old","respondedOn":"2009-11-01T20:00:00Z"}
+				]}`)
+			},
+			decision: &ApprovalDecision{Id: "2222", UserName: "asmith", Comments: "Ship it", RespondedOn: "2009-11-10T20:00:00Z"},
+		},
+		{
+			name: "no approval within the window",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{"decisions":[{"id":"3333","respondedOn":"2009-11-01T20:00:00Z"},{"id":"4444","respondedOn":"yesterday"}]}`)
+			},
+		},
+		{
+			name: "API failure",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 500,
+					Status:     "500 Internal Server Error",
+					Body:       io.NopCloser(bytes.NewBufferString(`internal error`)),
+				}, nil
+			},
+			err: "failed to look up earlier approvals: failed to send event: \nPOST http://test.com/v1/workflows/approval/decisions\nHTTP/500 500 Internal Server Error\n",
+		},
+		{
+			name: "not supported",
+			respGenFunc: func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString(`not found`)),
+				}, nil
+			},
+			err: "reuseApprovalWithin is ignored as the platform API does not support looking up earlier approvals: failed to send event: \nPOST http://test.com/v1/workflows/approval/decisions\nHTTP/404 404 Not Found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			os.Setenv("URL", "http://test.com")
+			defer os.Unsetenv("URL")
+			os.Setenv("API_TOKEN", "test")
+			defer os.Unsetenv("API_TOKEN")
+			defer func(n func() time.Time) { now = n }(now)
+			now = func() time.Time { return time.Date(2009, 11, 10, 23, 30, 0, 0, time.UTC) }
+
+			var requests []map[string]interface{}
+
+			// Run
+			c := Config{
+				Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+					"/v1/workflows/approval/decisions": tt.respGenFunc,
+				}),
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {},
+				},
+			}
+			decision, err := c.reusableDecision("sha256:1234", 24*time.Hour)
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.decision, decision)
+		})
+	}
+}
+
+func Test_initReusesApproval(t *testing.T) {
+	// Prepare
+	outputs := t.TempDir()
+	status := filepath.Join(t.TempDir(), "status")
+	evidence := t.TempDir()
+	for k, v := range map[string]string{
+		"URL":                   "http://test.com",
+		"API_TOKEN":             "test",
+		"CLOUDBEES_STATUS":      status,
+		"CLOUDBEES_OUTPUTS":     outputs,
+		"APPROVERS":             "123",
+		"INPUTS":                "region:\n  type: string\ntoken:\n  type: secret\n",
+		"REUSE_KEY":             "sha256:1234",
+		"REUSE_APPROVAL_WITHIN": "1d",
+		"EVIDENCE_FORMAT":       "json",
+		"EVIDENCE_DIR":          evidence,
+	} {
+		os.Setenv(k, v)
+		defer func(k string) {
+			os.Unsetenv(k)
+		}(k)
+	}
+	defer func(n func() time.Time) { now = n }(now)
+	now = func() time.Time { return time.Date(2009, 11, 10, 23, 30, 0, 0, time.UTC) }
+	defer func(n func() (string, error)) { newApprovalId = n }(newApprovalId)
+	newApprovalId = func() (string, error) { return "5678", nil }
+
+	var requests []map[string]interface{}
+	var testOutput []string
+	c := Config{
+		Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+			"/v1/workflows/approval/decisions": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{"decisions":[{"id":"1111","url":"https://cloudbees.io/runs/1","userId":"123","userName":"jdoe","comments":"LGTM","respondedOn":"2009-11-10T08:00:00Z",
+					"inputs":[{"name":"region","value":"eu","is_default":false},{"name":"token","value":"s3cr3t"}]}]}`)
+			},
+		}),
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	err := c.init()
+
+	// Verify
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	require.Equal(t, []string{
+		"Reusing the approval of the approval request 1111 (https://cloudbees.io/runs/1) with the reuse key 'sha256:1234'\n",
+		"Approved by jdoe on 2009-11-10T08:00:00Z with comments:\nLGTM\n",
+		"\nInput Parameters:\n",
+		"------------------\n",
+		" region: eu \n",
+		" token: ******** \n",
+		"Approval evidence written to " + filepath.Join(evidence, "manual-approval-5678.json") + "\n",
+	}, testOutput)
+
+	for name, expected := range map[string]string{
+		"comments":            "LGTM",
+		"approvalInputValues": `{"region":"eu","token":"********"}`,
+		"timeToDecision":      "0",
+		"approvalState":       `{"version":1,"approvalId":"5678","approvers":["123"],"inputs":"region:\n  type: string\ntoken:\n  type: secret\n","requestedOn":"2009-11-10T23:30:00Z","decidedOn":"2009-11-10T23:30:00Z","outcome":"approved","reusedFrom":"1111"}`,
+		"approvalSummary":     "## Manual approval: Approved\n\n| | |\n|---|---|\n| Decision | Approved |\n| Approver | jdoe |\n| Reused from | 1111 (https://cloudbees.io/runs/1) |\n| Requested on | 2009-11-10T23:30:00Z |\n| Decided on | 2009-11-10T23:30:00Z |\n| Time waited | 0s |\n\n### Input parameters\n\n| Name | Value |\n|---|---|\n| region | eu |\n| token | ******** |\n\n### Comments\n\n> LGTM\n",
+	} {
+		out, err := os.ReadFile(filepath.Join(outputs, name))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(out), name)
+	}
+
+	out, err := os.ReadFile(status)
+	require.NoError(t, err)
+	require.Equal(t, `{"message":"Reused an earlier approval of the workflow manual approval request","status":"APPROVED"}`, string(out))
+
+	out, err = os.ReadFile(filepath.Join(evidence, "manual-approval-5678.json"))
+	require.NoError(t, err)
+	parsedEvidence := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(out, &parsedEvidence))
+	require.Equal(t, "approved", parsedEvidence["outcome"])
+	require.Equal(t, "1111", parsedEvidence["reusedFrom"])
+	require.Equal(t, "jdoe", parsedEvidence["response"].(map[string]interface{})["userName"])
+}
+
+func Test_initReusedApprovalNotValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		warning string
+	}{
+		{
+			name:    "disallowed user",
+			env:     map[string]string{"DISALLOW_USERS": "jdoe@example.com"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: user 'jdoe' (jdoe@example.com) is not allowed to respond to this approval request as it matches the disallowUsers entry 'jdoe@example.com'\n",
+		},
+		{
+			name:    "not a requested approver",
+			env:     map[string]string{"APPROVERS": "789,jdoe"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: approver 'jdoe' is not one of the requested approvers\n",
+		},
+		{
+			name:    "member of a requested team",
+			env:     map[string]string{"APPROVERS": "team:ops"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: approver 'jdoe' is not one of the requested approvers\n",
+		},
+		{
+			name:    "launched by user not allowed",
+			env:     map[string]string{"DISALLOW_LAUNCHED_BY_USER": "true"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: approvals are not reused with disallowLaunchByUser, as the user who launched the workflow is not known\n",
+		},
+		{
+			name:    "comment required",
+			env:     map[string]string{"REQUIRE_COMMENT": "approved"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: a comment is required when the request is approved\n",
+		},
+		{
+			name:    "reason code required",
+			env:     map[string]string{"REASON_CODES": "planned,hotfix"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: a reason code is required, valid values are: planned, hotfix\n",
+		},
+		{
+			name:    "input value not valid for the current approvalInputs",
+			env:     map[string]string{"INPUTS": "region:\n  type: choice\n  options: [us, ap]\n"},
+			warning: "WARNING: The approval of the approval request 1111 is not reused: input 'region': value must be one of: us, ap\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			env := map[string]string{
+				"URL":                   "http://test.com",
+				"API_TOKEN":             "test",
+				"CLOUDBEES_STATUS":      filepath.Join(t.TempDir(), "status"),
+				"CLOUDBEES_OUTPUTS":     t.TempDir(),
+				"APPROVERS":             "456",
+				"REUSE_KEY":             "sha256:1234",
+				"REUSE_APPROVAL_WITHIN": "1d",
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			for k, v := range env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+			defer func(n func() time.Time) { now = n }(now)
+			now = func() time.Time { return time.Date(2009, 11, 10, 23, 30, 0, 0, time.UTC) }
+
+			var requests []map[string]interface{}
+			var testOutput []string
+			c := Config{
+				Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+					"/v1/workflows/approval/decisions": func(req map[string]interface{}) (*http.Response, error) {
+						return okResponse(`{"decisions":[{"id":"1111","userId":"456","userName":"jdoe","email":"jdoe@example.com","comments":"","respondedOn":"2009-11-10T08:00:00Z",
+							"inputs":[{"name":"region","value":"eu"}]}]}`)
+					},
+					"/v1/workflows/approval": func(req map[string]interface{}) (*http.Response, error) {
+						return okResponse(`{"id":"2222","approvers":[{"userName":"testUserName","userId":"123"}]}`)
+					},
+				}),
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+					},
+				},
+			}
+
+			// Run
+			err := c.init()
+
+			// Verify
+			require.NoError(t, err)
+			require.Len(t, requests, 2)
+			require.Equal(t, "/v1/workflows/approval", requests[1]["path"])
+			require.Equal(t, []string{
+				tt.warning,
+				"Waiting for approval from one of the following: testUserName\n",
+			}, testOutput)
+		})
+	}
+}
+
+func Test_initReuseNotSupported(t *testing.T) {
+	// Prepare
+	for k, v := range map[string]string{
+		"URL":                   "http://test.com",
+		"API_TOKEN":             "test",
+		"CLOUDBEES_STATUS":      filepath.Join(t.TempDir(), "status"),
+		"CLOUDBEES_OUTPUTS":     t.TempDir(),
+		"APPROVERS":             "123",
+		"REUSE_KEY":             "sha256:1234",
+		"REUSE_APPROVAL_WITHIN": "1d",
+	} {
+		os.Setenv(k, v)
+		defer func(k string) {
+			os.Unsetenv(k)
+		}(k)
+	}
+
+	var requests []map[string]interface{}
+	var testOutput []string
+	c := Config{
+		Client: mockApi(t, &requests, map[string]func(req map[string]interface{}) (*http.Response, error){
+			"/v1/workflows/approval/decisions": func(req map[string]interface{}) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString(`not found`)),
+				}, nil
+			},
+			"/v1/workflows/approval": func(req map[string]interface{}) (*http.Response, error) {
+				return okResponse(`{"id":"2222","approvers":[{"userName":"testUserName","userId":"123"}]}`)
+			},
+		}),
+		Output: &MockStdOut{
+			MockPrintf: func(format string, a ...any) {
+				testOutput = append(testOutput, fmt.Sprintf(format, a...))
+			},
+		},
+	}
+
+	// Run
+	err := c.init()
+
+	// Verify
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	require.Equal(t, "/v1/workflows/approval", requests[1]["path"])
+	require.NotContains(t, requests[1], "reuseKey")
+	require.Equal(t, []string{
+		"WARNING: reuseApprovalWithin is ignored as the platform API does not support looking up earlier approvals: failed to send event: \nPOST http://test.com/v1/workflows/approval/decisions\nHTTP/404 404 Not Found\n\n",
+		"Waiting for approval from one of the following: testUserName\n",
+	}, testOutput)
+}
+
+func Test_validateReusedApprover(t *testing.T) {
+	decision := &ApprovalDecision{Id: "1111", UserId: "456", UserName: "jdoe", Email: "JDoe@example.com"}
+	groups := []ownerGroup{
+		{owners: []approver{{kind: approverKindUser, name: "jdoe@example.com"}, {kind: approverKindUser, name: "789"}}, paths: []string{"api/"}},
+		{owners: []approver{{kind: approverKindTeam, name: "org/web"}}, paths: []string{"web/"}},
+	}
+
+	tests := []struct {
+		name                   string
+		approvers              []approver
+		groups                 []ownerGroup
+		disallowLaunchedByUser bool
+		err                    string
+	}{
+		{
+			name: "no approvers requested",
+		},
+		{
+			name:      "approver by user ID",
+			approvers: []approver{{kind: approverKindUser, name: "123"}, {kind: approverKindUser, name: "456"}},
+		},
+		{
+			name:      "approver by email",
+			approvers: []approver{{kind: approverKindUser, name: "jdoe@example.com", prefixed: true}},
+		},
+		{
+			name:      "not an approver",
+			approvers: []approver{{kind: approverKindUser, name: "123"}, {kind: approverKindTeam, name: "456"}},
+			err:       "approver 'jdoe' is not one of the requested approvers",
+		},
+		{
+			name:   "owner of the first group only",
+			groups: groups,
+			err:    "approver 'jdoe' is not one of the owners of web/",
+		},
+		{
+			name:   "owner of the group",
+			groups: groups[:1],
+		},
+		{
+			name:                   "launched by user not allowed",
+			disallowLaunchedByUser: true,
+			err:                    "approvals are not reused with disallowLaunchByUser, as the user who launched the workflow is not known",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			err := validateReusedApprover(decision, tt.approvers, tt.groups, tt.disallowLaunchedByUser)
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
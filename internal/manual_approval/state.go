@@ -0,0 +1,191 @@
+package manual_approval
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Name of the init output with the approval state, which is passed to the callback and cancel handlers
+const stateOutput = "approvalState"
+
+// Name of the approval state file in the state directory
+const stateFileName = "manual-approval-state.json"
+
+// Version of the approval state document
+const stateVersion = 1
+
+// approvalState is written by the init handler and read by the callback and cancel handlers,
+// which run as separate processes. The handlers which decide on the approval add the decision.
+type approvalState struct {
+	Version            int        `json:"version"`
+	ApprovalId         string     `json:"approvalId"`
+	Approvers          []string   `json:"approvers,omitempty"`
+	ApproverGroups     [][]string `json:"approverGroups,omitempty"`
+	Inputs             string     `json:"inputs,omitempty"`
+	Instructions       string     `json:"instructions,omitempty"`
+	InstructionsSha256 string     `json:"instructionsSha256,omitempty"`
+	RequestedOn        time.Time  `json:"requestedOn"`
+	DecidedOn          *time.Time `json:"decidedOn,omitempty"`
+	Outcome            string     `json:"outcome,omitempty"`
+	ReusedFrom         string     `json:"reusedFrom,omitempty"`
+}
+
+// stateStore persists the approval state between the handlers
+type stateStore interface {
+	// Load the approval state, which is nil if the init handler did not save it
+	load() (*approvalState, error)
+	save(state *approvalState) error
+}
+
+// The approval state is stored in a file of the state directory when it is configured,
+// otherwise it is passed from the outputs of the init handler to the other handlers
+func (k *Config) stateStore() (stateStore, error) {
+	dir := os.Getenv("STATE_DIR")
+	if dir == "" {
+		return &outputsStateStore{k: k}, nil
+	}
+	dir, err := workspacePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStateStore{k: k, path: filepath.Join(dir, stateFileName)}, nil
+}
+
+// Load the approval state from the configured store
+func (k *Config) loadState() (*approvalState, error) {
+	store, err := k.stateStore()
+	if err != nil {
+		return nil, err
+	}
+	state, err := store.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the approval state: %w", err)
+	}
+	logger.Debug("Approval state", "state", state)
+	return state, nil
+}
+
+// Save the approval state to the configured store
+func (k *Config) saveState(state *approvalState) error {
+	store, err := k.stateStore()
+	if err != nil {
+		return err
+	}
+	if err := store.save(state); err != nil {
+		return fmt.Errorf("failed to save the approval state: %w", err)
+	}
+	return nil
+}
+
+// Record the decision in the approval state. Approvals requested without a state are left as they are.
+func (k *Config) saveDecision(state *approvalState, outcome string, decidedOn time.Time) error {
+	if state == nil {
+		return nil
+	}
+	decidedOn = decidedOn.UTC()
+	state.DecidedOn = &decidedOn
+	state.Outcome = outcome
+	return k.saveState(state)
+}
+
+// outputsStateStore writes the state to an output of the handler. The custom job passes the
+// output of the init handler to the other handlers in the APPROVAL_STATE environment variable.
+type outputsStateStore struct {
+	k *Config
+}
+
+func (s *outputsStateStore) load() (*approvalState, error) {
+	return decodeState([]byte(os.Getenv("APPROVAL_STATE")))
+}
+
+func (s *outputsStateStore) save(state *approvalState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	// the instructions are only used for the summary report, so they are dropped when they do not fit
+	if len(data) > maxOutputSize && state.Instructions != "" {
+		s.k.Output.Printf("WARNING: The instructions are not added to the approval summary as they exceed the maximum output size of %d bytes, configure a state directory instead\n", maxOutputSize)
+		trimmed := *state
+		trimmed.Instructions = ""
+		data, err = json.Marshal(&trimmed)
+		if err != nil {
+			return err
+		}
+	}
+	if len(data) > maxOutputSize {
+		return fmt.Errorf("the approval state exceeds the maximum output size of %d bytes, configure a state directory instead", maxOutputSize)
+	}
+	return s.k.writeAsOutput(stateOutput, data)
+}
+
+// fileStateStore writes the state to a file, which is shared by the handlers through the workspace
+type fileStateStore struct {
+	k    *Config
+	path string
+}
+
+func (s *fileStateStore) load() (*approvalState, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeState(data)
+}
+
+func (s *fileStateStore) save(state *approvalState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.k.DryRun {
+		s.k.Output.Printf("DRY RUN: state '%s':\n%s\n", s.path, data)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+func decodeState(data []byte) (*approvalState, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	state := &approvalState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Version > stateVersion {
+		return nil, fmt.Errorf("unsupported approval state version %d", state.Version)
+	}
+	return state, nil
+}
+
+// Generate a random ID for approval requests for which the platform does not return one, replaced in tests
+var newApprovalId = func() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Get the SHA-256 hash of the instructions, which identifies the instructions the approvers saw
+func instructionsSha256(instructions string) string {
+	if instructions == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(instructions))
+	return hex.EncodeToString(sum[:])
+}
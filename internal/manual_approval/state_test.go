@@ -0,0 +1,119 @@
+package manual_approval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fileStateStore(t *testing.T) {
+	// Prepare
+	workspace := t.TempDir()
+	os.Setenv("CLOUDBEES_WORKSPACE", workspace)
+	defer os.Unsetenv("CLOUDBEES_WORKSPACE")
+	os.Setenv("STATE_DIR", ".approval")
+	defer os.Unsetenv("STATE_DIR")
+
+	c := Config{}
+
+	// Run
+	state, err := c.loadState()
+	require.NoError(t, err)
+	require.Nil(t, state)
+
+	requested := &approvalState{
+		Version:            stateVersion,
+		ApprovalId:         "1234",
+		Approvers:          []string{"user:jdoe", "team:release"},
+		ApproverGroups:     [][]string{{"team:release"}},
+		Inputs:             approvalInputs,
+		InstructionsSha256: instructionsSha256("instructions"),
+		RequestedOn:        time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, c.saveState(requested))
+	require.NoError(t, c.saveDecision(requested, outcomeRejected, time.Date(2009, 11, 10, 23, 0, 0, 0, time.FixedZone("CET", 3600))))
+
+	// Verify
+	_, err = os.Stat(filepath.Join(workspace, ".approval", stateFileName))
+	require.NoError(t, err)
+
+	state, err = c.loadState()
+	require.NoError(t, err)
+	decidedOn := time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC)
+	require.Equal(t, &approvalState{
+		Version:            stateVersion,
+		ApprovalId:         "1234",
+		Approvers:          []string{"user:jdoe", "team:release"},
+		ApproverGroups:     [][]string{{"team:release"}},
+		Inputs:             approvalInputs,
+		InstructionsSha256: "238fa28a94976c7da14563bc873c2729bd5cd325389085bb4c6dd0de28923590",
+		RequestedOn:        time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC),
+		DecidedOn:          &decidedOn,
+		Outcome:            outcomeRejected,
+	}, state)
+}
+
+func Test_loadState(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		state *approvalState
+		err   string
+	}{
+		{
+			name: "no state",
+		},
+		{
+			name: "state from the init outputs",
+			env:  map[string]string{"APPROVAL_STATE": `{"version":1,"approvalId":"1234","approvers":["123"],"requestedOn":"2009-11-10T22:00:00Z"}`},
+			state: &approvalState{
+				Version:     1,
+				ApprovalId:  "1234",
+				Approvers:   []string{"123"},
+				RequestedOn: time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "invalid state",
+			env:  map[string]string{"APPROVAL_STATE": `{"version":1,`},
+			err:  "failed to load the approval state: unexpected end of JSON input",
+		},
+		{
+			name: "unsupported version",
+			env:  map[string]string{"APPROVAL_STATE": `{"version":2,"approvalId":"1234"}`},
+			err:  "failed to load the approval state: unsupported approval state version 2",
+		},
+		{
+			name: "state directory outside of the workspace",
+			env:  map[string]string{"CLOUDBEES_WORKSPACE": "/tmp/workspace", "STATE_DIR": "../state"},
+			err:  "/tmp/state is outside of the workspace /tmp/workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+
+			// Run
+			c := Config{}
+			state, err := c.loadState()
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.state, state)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package manual_approval
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Names of the outputs with the summary report of the approval
+const (
+	summaryOutput     = "approvalSummary"
+	summaryHtmlOutput = "approvalSummaryHtml"
+)
+
+// Titles of the approval outcomes in the summary report
+var outcomeTitles = map[string]string{
+	outcomeApproved:     "Approved",
+	outcomeRejected:     "Rejected",
+	outcomeAborted:      "Aborted",
+	outcomeTimedOut:     "Timed out",
+	outcomeSuperseded:   "Superseded",
+	outcomePolicyDenied: "Denied by policy",
+	outcomeUnknown:      "Cancelled",
+}
+
+// approvalSummary is the report of a decided approval shown on the run details page
+type approvalSummary struct {
+	Outcome      string
+	Approver     string
+	ReusedFrom   string
+	RequestedOn  time.Time
+	DecidedOn    time.Time
+	Instructions string
+	Inputs       []summaryInput
+	Comments     string
+}
+
+type summaryInput struct {
+	Name      string
+	Value     string
+	IsDefault bool
+}
+
+// Create the summary of a decision. The request time and instructions are only known
+// for approvals requested with an approval state.
+func newApprovalSummary(state *approvalState, outcome string, decidedOn time.Time) approvalSummary {
+	s := approvalSummary{Outcome: outcome, DecidedOn: decidedOn}
+	if state != nil {
+		s.RequestedOn = state.RequestedOn
+		s.Instructions = state.Instructions
+	}
+	return s
+}
+
+// Get the input values of the summary from the inputs posted to the API, whose values are strings
+func summaryInputs(inputsForPost []interface{}) []summaryInput {
+	inputs := make([]summaryInput, 0, len(inputsForPost))
+	for _, input := range inputsForPost {
+		ip := input.(map[string]interface{})
+		name, _ := ip["name"].(string)
+		value, _ := ip["value"].(string)
+		inputs = append(inputs, summaryInput{Name: name, Value: value, IsDefault: ip["is_default"] == true})
+	}
+	return inputs
+}
+
+// Get the rows of the details table, skipping unknown values
+func (s approvalSummary) details() [][2]string {
+	rows := [][2]string{{"Decision", s.title()}}
+	if s.Approver != "" {
+		rows = append(rows, [2]string{"Approver", s.Approver})
+	}
+	if s.ReusedFrom != "" {
+		rows = append(rows, [2]string{"Reused from", s.ReusedFrom})
+	}
+	if !s.RequestedOn.IsZero() {
+		rows = append(rows, [2]string{"Requested on", s.RequestedOn.UTC().Format(time.RFC3339)})
+	}
+	rows = append(rows, [2]string{"Decided on", s.DecidedOn.UTC().Format(time.RFC3339)})
+	if !s.RequestedOn.IsZero() {
+		waited := s.DecidedOn.Sub(s.RequestedOn)
+		if waited < 0 {
+			waited = 0
+		}
+		rows = append(rows, [2]string{"Time waited", waited.Round(time.Second).String()})
+	}
+	return rows
+}
+
+func (s approvalSummary) title() string {
+	if title, ok := outcomeTitles[s.Outcome]; ok {
+		return title
+	}
+	return s.Outcome
+}
+
+func (i summaryInput) displayValue() string {
+	if i.IsDefault {
+		return i.Value + " (default)"
+	}
+	return i.Value
+}
+
+// Render the summary as markdown
+func (s approvalSummary) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Manual approval: %s\n\n", s.title())
+
+	b.WriteString("| | |\n|---|---|\n")
+	for _, row := range s.details() {
+		fmt.Fprintf(&b, "| %s | %s |\n", row[0], markdownCell(row[1]))
+	}
+
+	if s.Instructions != "" {
+		fmt.Fprintf(&b, "\n### Instructions\n\n%s\n", strings.TrimRight(s.Instructions, "\n"))
+	}
+
+	if len(s.Inputs) > 0 {
+		b.WriteString("\n### Input parameters\n\n| Name | Value |\n|---|---|\n")
+		for _, input := range s.Inputs {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownCell(input.Name), markdownCell(input.displayValue()))
+		}
+	}
+
+	if s.Comments != "" {
+		b.WriteString("\n### Comments\n\n")
+		for _, line := range strings.Split(strings.TrimRight(s.Comments, "\n"), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Render the summary as HTML. The instructions are rendered from markdown and sanitized,
+// the other values are escaped.
+func (s approvalSummary) html() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h2>Manual approval: %s</h2>\n", html.EscapeString(s.title()))
+
+	b.WriteString("<table>\n")
+	for _, row := range s.details() {
+		fmt.Fprintf(&b, "<tr><th>%s</th><td>%s</td></tr>\n", row[0], htmlCell(row[1]))
+	}
+	b.WriteString("</table>\n")
+
+	if s.Instructions != "" {
+		fmt.Fprintf(&b, "<h3>Instructions</h3>\n%s", sanitizeHtml(markdown(s.Instructions)))
+	}
+
+	if len(s.Inputs) > 0 {
+		b.WriteString("<h3>Input parameters</h3>\n<table>\n<tr><th>Name</th><th>Value</th></tr>\n")
+		for _, input := range s.Inputs {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td></tr>\n", htmlCell(input.Name), htmlCell(input.displayValue()))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	if s.Comments != "" {
+		fmt.Fprintf(&b, "<h3>Comments</h3>\n<blockquote>%s</blockquote>\n", htmlCell(strings.TrimRight(s.Comments, "\n")))
+	}
+	return b.String()
+}
+
+// Escape a value for a markdown table cell, which cannot span lines
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+func htmlCell(value string) string {
+	return strings.ReplaceAll(html.EscapeString(value), "\n", "<br>")
+}
+
+// Write the summary report to the outputs in markdown and HTML
+func (k *Config) writeSummary(s approvalSummary) error {
+	err := k.writeAsOutput(summaryOutput, []byte(s.markdown()))
+	if err != nil {
+		return err
+	}
+	return k.writeAsOutput(summaryHtmlOutput, []byte(s.html()))
+}
@@ -0,0 +1,87 @@
+package manual_approval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_approvalSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		summary  approvalSummary
+		markdown string
+		html     string
+	}{
+		{
+			name: "rejected with inputs",
+			summary: approvalSummary{
+				Outcome:      outcomeRejected,
+				Approver:     "Some One",
+				RequestedOn:  time.Date(2009, 11, 10, 22, 0, 0, 0, time.UTC),
+				DecidedOn:    time.Date(2009, 11, 10, 23, 1, 30, 0, time.UTC),
+				Instructions: "Check the <b>release notes</b>\n\n- [ ] rollback plan\n",
+				Inputs: []summaryInput{
+					{Name: "target", Value: "staging", IsDefault: true},
+					{Name: "notes", Value: "a|b\n<c>"},
+				},
+				Comments: "not yet\n\nretry tomorrow\n",
+			},
+			markdown: "## Manual approval: Rejected\n\n" +
+				"| | |\n|---|---|\n" +
+				"| Decision | Rejected |\n" +
+				"| Approver | Some One |\n" +
+				"| Requested on | 2009-11-10T22:00:00Z |\n" +
+				"| Decided on | 2009-11-10T23:01:30Z |\n" +
+				"| Time waited | 1h1m30s |\n\n" +
+				"### Instructions\n\nCheck the <b>release notes</b>\n\n- [ ] rollback plan\n\n" +
+				"### Input parameters\n\n| Name | Value |\n|---|---|\n" +
+				"| target | staging (default) |\n" +
+				"| notes | a\\|b<br><c> |\n\n" +
+				"### Comments\n\n> not yet\n>\n> retry tomorrow\n",
+			html: "<h2>Manual approval: Rejected</h2>\n<table>\n" +
+				"<tr><th>Decision</th><td>Rejected</td></tr>\n" +
+				"<tr><th>Approver</th><td>Some One</td></tr>\n" +
+				"<tr><th>Requested on</th><td>2009-11-10T22:00:00Z</td></tr>\n" +
+				"<tr><th>Decided on</th><td>2009-11-10T23:01:30Z</td></tr>\n" +
+				"<tr><th>Time waited</th><td>1h1m30s</td></tr>\n</table>\n" +
+				"<h3>Instructions</h3>\n<p>Check the release notes</p>\n<ul>\n<li><input disabled=\"\" type=\"checkbox\"> rollback plan</li>\n</ul>\n" +
+				"<h3>Input parameters</h3>\n<table>\n<tr><th>Name</th><th>Value</th></tr>\n" +
+				"<tr><td>target</td><td>staging (default)</td></tr>\n" +
+				"<tr><td>notes</td><td>a|b<br>&lt;c&gt;</td></tr>\n</table>\n" +
+				"<h3>Comments</h3>\n<blockquote>not yet<br><br>retry tomorrow</blockquote>\n",
+		},
+		{
+			name: "timed out without approval state",
+			summary: approvalSummary{
+				Outcome:   outcomeTimedOut,
+				DecidedOn: time.Date(2009, 11, 10, 23, 0, 0, 0, time.FixedZone("CET", 3600)),
+			},
+			markdown: "## Manual approval: Timed out\n\n" +
+				"| | |\n|---|---|\n" +
+				"| Decision | Timed out |\n" +
+				"| Decided on | 2009-11-10T22:00:00Z |\n",
+			html: "<h2>Manual approval: Timed out</h2>\n<table>\n" +
+				"<tr><th>Decision</th><td>Timed out</td></tr>\n" +
+				"<tr><th>Decided on</th><td>2009-11-10T22:00:00Z</td></tr>\n</table>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.markdown, tt.summary.markdown())
+			require.Equal(t, tt.html, tt.summary.html())
+		})
+	}
+}
+
+func Test_summaryInputs(t *testing.T) {
+	inputs := summaryInputs([]interface{}{
+		map[string]interface{}{"name": "in1", "value": "abc", "is_default": true},
+		map[string]interface{}{"name": "in2", "value": "99.33", "is_default": false},
+	})
+	require.Equal(t, []summaryInput{
+		{Name: "in1", Value: "abc", IsDefault: true},
+		{Name: "in2", Value: "99.33"},
+	}, inputs)
+}
@@ -0,0 +1,267 @@
+package manual_approval
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Supported formats for writing the instructions to the job log
+const (
+	logFormatText = "text"
+	logFormatAnsi = "ansi"
+	logFormatHtml = "html"
+)
+
+// ANSI escape sequences used for terminal rendering
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiStrike    = "\x1b[9m"
+	ansiCyan      = "\x1b[36m"
+)
+
+func parseLogFormat(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", logFormatText:
+		return logFormatText, nil
+	case logFormatAnsi:
+		return logFormatAnsi, nil
+	case logFormatHtml:
+		return logFormatHtml, nil
+	default:
+		return "", fmt.Errorf("unsupported instructions log format '%s', valid values are: %s, %s, %s", value, logFormatText, logFormatAnsi, logFormatHtml)
+	}
+}
+
+// Render the instructions for the job log in the given format
+func formatInstructionsForLog(instructions string, instructionsHtml string, format string) string {
+	switch format {
+	case logFormatHtml:
+		return instructionsHtml
+	case logFormatAnsi:
+		return terminal(instructions, true)
+	default:
+		return terminal(instructions, false)
+	}
+}
+
+// Convert markdown to readable plain text, optionally styled with ANSI escape sequences
+func terminal(value string, ansi bool) string {
+	source := []byte(value)
+	doc := md.Parser().Parse(text.NewReader(source))
+	r := &terminalRenderer{source: source, ansi: ansi}
+	lines := r.blocks(doc, false)
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+type terminalRenderer struct {
+	source []byte
+	ansi   bool
+}
+
+func (r *terminalRenderer) style(s string, codes ...string) string {
+	if !r.ansi || s == "" {
+		return s
+	}
+	return strings.Join(codes, "") + s + ansiReset
+}
+
+// Render the child blocks of a node, separated by blank lines unless tight
+func (r *terminalRenderer) blocks(n ast.Node, tight bool) []string {
+	var lines []string
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		block := r.block(c)
+		if len(block) == 0 {
+			continue
+		}
+		if len(lines) > 0 && !tight {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+	}
+	return lines
+}
+
+func (r *terminalRenderer) block(n ast.Node) []string {
+	switch v := n.(type) {
+	case *ast.Heading:
+		return r.heading(v)
+	case *ast.Paragraph, *ast.TextBlock:
+		return strings.Split(r.inline(v), "\n")
+	case *ast.Blockquote:
+		var lines []string
+		for _, line := range r.blocks(v, false) {
+			lines = append(lines, strings.TrimRight("> "+line, " "))
+		}
+		return lines
+	case *ast.List:
+		return r.list(v)
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		var lines []string
+		segments := v.Lines()
+		for i := 0; i < segments.Len(); i++ {
+			segment := segments.At(i)
+			line := strings.TrimRight(string(segment.Value(r.source)), "\n")
+			lines = append(lines, "    "+r.style(line, ansiDim))
+		}
+		return lines
+	case *east.Table:
+		return r.table(v)
+	case *ast.ThematicBreak:
+		return []string{"----------"}
+	case *ast.HTMLBlock:
+		// raw html is not rendered in the log
+		return nil
+	default:
+		return r.blocks(v, false)
+	}
+}
+
+func (r *terminalRenderer) heading(h *ast.Heading) []string {
+	title := r.inline(h)
+	width := utf8.RuneCountInString(title)
+	switch h.Level {
+	case 1:
+		return []string{r.style(title, ansiBold, ansiUnderline), strings.Repeat("=", width)}
+	case 2:
+		return []string{r.style(title, ansiBold), strings.Repeat("-", width)}
+	default:
+		return []string{r.style(strings.Repeat("#", h.Level)+" "+title, ansiBold)}
+	}
+}
+
+func (r *terminalRenderer) list(l *ast.List) []string {
+	var lines []string
+	number := l.Start
+	for item := l.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "- "
+		if l.IsOrdered() {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		indent := strings.Repeat(" ", len(marker))
+
+		if len(lines) > 0 && !l.IsTight {
+			lines = append(lines, "")
+		}
+		for i, line := range r.blocks(item, l.IsTight) {
+			switch {
+			case i == 0:
+				lines = append(lines, marker+line)
+			case line == "":
+				lines = append(lines, "")
+			default:
+				lines = append(lines, indent+line)
+			}
+		}
+	}
+	return lines
+}
+
+func (r *terminalRenderer) table(t *east.Table) []string {
+	var rows [][]string
+	var widths []int
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			i := len(cells)
+			cells = append(cells, r.inline(cell))
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cells[i]))
+		}
+		rows = append(rows, cells)
+	}
+
+	var lines []string
+	for i, cells := range rows {
+		padded := make([]string, len(cells))
+		for j, cell := range cells {
+			padded[j] = cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+			if i == 0 {
+				padded[j] = r.style(padded[j], ansiBold)
+			}
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(padded, " | "), " "))
+
+		// separate the header from the body
+		if i == 0 {
+			separators := make([]string, len(widths))
+			for j, w := range widths {
+				separators[j] = strings.Repeat("-", w)
+			}
+			lines = append(lines, strings.Join(separators, "-|-"))
+		}
+	}
+	return lines
+}
+
+func (r *terminalRenderer) inline(n ast.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch v := c.(type) {
+		case *ast.Text:
+			sb.Write(v.Segment.Value(r.source))
+			if v.SoftLineBreak() || v.HardLineBreak() {
+				sb.WriteString("\n")
+			}
+		case *ast.String:
+			sb.Write(v.Value)
+		case *ast.CodeSpan:
+			code := r.inline(v)
+			if r.ansi {
+				sb.WriteString(r.style(code, ansiCyan))
+			} else {
+				sb.WriteString("`" + code + "`")
+			}
+		case *ast.Emphasis:
+			if v.Level >= 2 {
+				sb.WriteString(r.style(r.inline(v), ansiBold))
+			} else {
+				sb.WriteString(r.style(r.inline(v), ansiItalic))
+			}
+		case *ast.Link:
+			sb.WriteString(r.link(r.inline(v), string(v.Destination)))
+		case *ast.AutoLink:
+			sb.WriteString(r.style(string(v.URL(r.source)), ansiUnderline))
+		case *ast.Image:
+			sb.WriteString(r.link(r.inline(v), string(v.Destination)))
+		case *east.TaskCheckBox:
+			if v.IsChecked {
+				sb.WriteString("[x] ")
+			} else {
+				sb.WriteString("[ ] ")
+			}
+		case *east.Strikethrough:
+			if r.ansi {
+				sb.WriteString(r.style(r.inline(v), ansiStrike))
+			} else {
+				sb.WriteString("~~" + r.inline(v) + "~~")
+			}
+		case *ast.RawHTML:
+			// raw html is not rendered in the log
+		default:
+			sb.WriteString(r.inline(v))
+		}
+	}
+	return sb.String()
+}
+
+func (r *terminalRenderer) link(label string, destination string) string {
+	if label == "" || label == destination {
+		return r.style(destination, ansiUnderline)
+	}
+	return r.style(label, ansiUnderline) + " (" + destination + ")"
+}
@@ -0,0 +1,52 @@
+package manual_approval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_terminal(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		ansi   bool
+		output string
+	}{
+		{
+			name:   "Plain text",
+			input:  instructionsInput,
+			output: instructionsText,
+		},
+		{
+			name:   "Plain text ordered list, code block and links",
+			input:  "1. first\n2. second\n\n```sh\nkubectl rollout undo\n```\n\nSee [runbook](https://example.com/runbook) or <https://example.com>\n\n---\n",
+			output: "1. first\n2. second\n\n    kubectl rollout undo\n\nSee runbook (https://example.com/runbook) or https://example.com\n\n----------\n",
+		},
+		{
+			name:   "ANSI",
+			input:  "# Title\n\n**bold** *italic* `code` [link](https://example.com)\n",
+			ansi:   true,
+			output: "\x1b[1m\x1b[4mTitle\x1b[0m\n=====\n\n\x1b[1mbold\x1b[0m \x1b[3mitalic\x1b[0m \x1b[36mcode\x1b[0m \x1b[4mlink\x1b[0m (https://example.com)\n",
+		},
+		{
+			name:   "GitHub-flavored markdown",
+			input:  "| Service | Version |\n|---|---|\n| api | 1.2.3 |\n\n- [ ] Rollback plan verified\n- [x] Release notes published\n\n~~Friday~~ deploys, see https://example.com\n",
+			output: "Service | Version\n--------|--------\napi     | 1.2.3\n\n- [ ] Rollback plan verified\n- [x] Release notes published\n\n~~Friday~~ deploys, see https://example.com\n",
+		},
+		{
+			name:   "Raw html is dropped",
+			input:  "<script>alert('x')</script>\n\ntext",
+			output: "text\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			result := terminal(tt.input, tt.ansi)
+
+			// Verify
+			require.Equal(t, tt.output, result)
+		})
+	}
+}
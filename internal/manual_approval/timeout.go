@@ -0,0 +1,104 @@
+package manual_approval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Actions of the cancel handler when no approver responds within the allotted time
+const (
+	onTimeoutFail    = "fail"
+	onTimeoutApprove = "approve"
+	onTimeoutReject  = "reject"
+)
+
+// Decisions taken automatically when the approval request times out, keyed by the onTimeout action
+var timeoutDecisions = map[string]cancellation{
+	onTimeoutApprove: {
+		outcome:   outcomeApproved,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+		jobStatus: "APPROVED",
+		messages:  []string{"Workflow timed out", "Workflow approval response was not received within allotted time.", "Automatically approving the manual approval request"},
+		automatic: true,
+	},
+	onTimeoutReject: {
+		outcome:   outcomeRejected,
+		apiStatus: "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+		jobStatus: "REJECTED",
+		messages:  []string{"Workflow timed out", "Workflow approval response was not received within allotted time.", "Automatically rejecting the manual approval request"},
+		automatic: true,
+	},
+}
+
+// Parse the action taken when the approval request times out, which fails the job by default
+func parseOnTimeout(value string) (string, error) {
+	action := strings.ToLower(strings.TrimSpace(value))
+	switch action {
+	case "":
+		return onTimeoutFail, nil
+	case onTimeoutFail, onTimeoutApprove, onTimeoutReject:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unsupported onTimeout value '%s', valid values are: %s, %s, %s", value, onTimeoutFail, onTimeoutApprove, onTimeoutReject)
+	}
+}
+
+// Get how to close a timed out approval request for the onTimeout action. Other cancellations
+// are closed as they are.
+func (c cancellation) onTimeout(action string) cancellation {
+	if decision, ok := timeoutDecisions[action]; ok && c.outcome == outcomeTimedOut {
+		return decision
+	}
+	return c
+}
+
+// Get the comments of an automatic decision, which state that no approver decided,
+// followed by the configured comments
+func automaticComments(c cancellation, comments string) string {
+	decision := "approved"
+	if c.outcome == outcomeRejected {
+		decision = "rejected"
+	}
+	automatic := fmt.Sprintf("Automatically %s as the approval response was not received within allotted time.", decision)
+	if comments = strings.TrimSpace(comments); comments != "" {
+		automatic += "\n\n" + comments
+	}
+	return automatic
+}
+
+// Check that the required inputs shown to the approvers have a default value, as an automatic
+// decision has no other value for them
+func validateDefaultInputs(defs []inputDefinition, values map[string]interface{}) error {
+	hidden := hiddenInputs(defs, values)
+	for _, def := range defs {
+		if !def.Required || hidden[def.Name] {
+			continue
+		}
+		if value, ok := values[def.Name]; !ok || value == nil || value == "" {
+			return fmt.Errorf("input '%s' is required and has no default value", def.Name)
+		}
+	}
+	return nil
+}
+
+// Get the inputs of an automatic decision in the format of the approval response, which are
+// the default values of the inputs shown to the approvers
+func defaultInputs(defs []inputDefinition) []interface{} {
+	values := make(map[string]interface{})
+	for _, def := range defs {
+		if def.Default != nil {
+			values[def.Name] = def.Default
+		}
+	}
+
+	hidden := hiddenInputs(defs, values)
+	inputs := make([]interface{}, 0, len(values))
+	for _, def := range defs {
+		value, ok := values[def.Name]
+		if !ok || hidden[def.Name] {
+			continue
+		}
+		inputs = append(inputs, map[string]interface{}{"name": def.Name, "value": value, "is_default": true})
+	}
+	return inputs
+}
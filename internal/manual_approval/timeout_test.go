@@ -0,0 +1,140 @@
+package manual_approval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseOnTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		action string
+		err    string
+	}{
+		{
+			name:   "default",
+			value:  "",
+			action: onTimeoutFail,
+		},
+		{
+			name:   "approve",
+			value:  " Approve ",
+			action: onTimeoutApprove,
+		},
+		{
+			name:   "reject",
+			value:  "reject",
+			action: onTimeoutReject,
+		},
+		{
+			name:  "unsupported",
+			value: "ignore",
+			err:   "unsupported onTimeout value 'ignore', valid values are: fail, approve, reject",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			action, err := parseOnTimeout(tt.value)
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.action, action)
+		})
+	}
+}
+
+func Test_onTimeout(t *testing.T) {
+	// Run
+	approved := cancellationOf("TIMED_OUT").onTimeout(onTimeoutApprove)
+	failed := cancellationOf("TIMED_OUT").onTimeout(onTimeoutFail)
+	aborted := cancellationOf("CANCELLED").onTimeout(onTimeoutReject)
+	unknown := cancellationOf("WORKFLOW_FAILED").onTimeout(onTimeoutApprove)
+
+	// Verify
+	require.True(t, approved.automatic)
+	require.Equal(t, outcomeApproved, approved.outcome)
+	require.Equal(t, "APPROVED", approved.jobStatus)
+	require.False(t, failed.automatic)
+	require.Equal(t, outcomeTimedOut, failed.outcome)
+	require.False(t, aborted.automatic)
+	require.Equal(t, outcomeAborted, aborted.outcome)
+	require.False(t, unknown.automatic)
+	require.Equal(t, outcomeUnknown, unknown.outcome)
+	require.Equal(t, "UPDATE_MANUAL_APPROVAL_STATUS_ABORTED", unknown.apiStatus)
+	require.Equal(t, "FAILED", unknown.jobStatus)
+}
+
+func Test_defaultInputs(t *testing.T) {
+	// Prepare
+	defs, err := parseInputs(`
+environment:
+  type: choice
+  options: [staging, production]
+  default: staging
+ticket:
+  type: string
+  default: OPS-1
+  when:
+    environment: production
+replicas:
+  type: number
+  default: 3
+notes:
+  type: string
+`)
+	require.NoError(t, err)
+
+	// Run
+	inputs := defaultInputs(defs)
+
+	// Verify
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "environment", "value": "staging", "is_default": true},
+		map[string]interface{}{"name": "replicas", "value": 3, "is_default": true},
+	}, inputs)
+}
+
+func Test_validateDefaultInputs(t *testing.T) {
+	// Prepare
+	defs, err := parseInputs(`
+environment:
+  type: choice
+  options: [staging, production]
+  default: staging
+  required: true
+ticket:
+  type: string
+  required: true
+  when:
+    environment: production
+notes:
+  type: string
+`)
+	require.NoError(t, err)
+	values := payloadInputValues(map[string]interface{}{"inputs": defaultInputs(defs)})
+
+	// Run
+	err = validateDefaultInputs(defs, values)
+
+	// Verify
+	require.NoError(t, err)
+
+	// Prepare
+	defs[0].Default = "production"
+	values = payloadInputValues(map[string]interface{}{"inputs": defaultInputs(defs)})
+
+	// Run
+	err = validateDefaultInputs(defs, values)
+
+	// Verify
+	require.Error(t, err)
+	require.Equal(t, "input 'ticket' is required and has no default value", err.Error())
+}
@@ -0,0 +1,151 @@
+package manual_approval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/cloudbees-io/manual-approval"
+
+// Supported trace exporters
+const (
+	traceExporterNone = "none"
+	traceExporterOtlp = "otlp"
+	traceExporterFile = "file"
+)
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Configure the trace exporter from the environment. The OTLP exporter is configured with the
+// standard OTEL_EXPORTER_OTLP_* variables, the file exporter writes the spans as JSON to OTEL_TRACES_FILE.
+// The returned function flushes the spans and must be called before exiting.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); exporterName {
+	case "", traceExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case traceExporterOtlp:
+		exporter, err = otlptracehttp.New(ctx)
+	case traceExporterFile:
+		exporter, err = newFileExporter(os.Getenv("OTEL_TRACES_FILE"))
+	default:
+		return nil, fmt.Errorf("unsupported traces exporter '%s', valid values are: %s, %s, %s", exporterName, traceExporterNone, traceExporterOtlp, traceExporterFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create traces exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "manual-approval"))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// File exporter for local runs, the spans are appended to the file as JSON
+func newFileExporter(path string) (sdktrace.SpanExporter, error) {
+	if path == "" {
+		return nil, fmt.Errorf("OTEL_TRACES_FILE environment variable missing")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{SpanExporter: exporter, file: f}, nil
+}
+
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if cerr := e.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Join the trace of the workflow, whose W3C trace context is read from TRACEPARENT and TRACESTATE
+func contextFromEnv(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+func (k *Config) ctx() context.Context {
+	if k.Context == nil {
+		return context.Background()
+	}
+	return k.Context
+}
+
+// Start a span as a child of the span in the context of the configuration. The context
+// of the configuration is replaced with the context of the new span until it ends.
+func (k *Config) startSpan(name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	parent := k.ctx()
+	ctx, span := tracer().Start(parent, name, trace.WithAttributes(attrs...))
+	k.Context = ctx
+	return span, func() {
+		span.End()
+		k.Context = parent
+	}
+}
+
+// Start an HTTP client span for the request and inject the W3C trace context headers
+func (k *Config) traceRequest(req *http.Request) (*http.Request, trace.Span) {
+	ctx, span := tracer().Start(k.ctx(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.String()),
+		),
+	)
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, span
+}
+
+// Record the outcome of an HTTP request and end its span
+func endRequestSpan(span trace.Span, resp *http.Response, err error) {
+	defer span.End()
+	if err != nil {
+		recordError(span, err)
+		return
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
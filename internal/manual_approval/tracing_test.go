@@ -0,0 +1,150 @@
+package manual_approval
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_tracing(t *testing.T) {
+	// Prepare
+	defer func(l *slog.Logger) {
+		logger = l
+	}(logger)
+	defer func(tp trace.TracerProvider) {
+		otel.SetTracerProvider(tp)
+	}(otel.GetTracerProvider())
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	env := map[string]string{
+		"URL":                 "http://test.com",
+		"API_TOKEN":           "test",
+		"CLOUDBEES_STATUS":    "/tmp/test-status-out",
+		"CLOUDBEES_OUTPUTS":   t.TempDir(),
+		"CANCELLATION_REASON": "TIMED_OUT",
+		"TRACEPARENT":         "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer func(k string) {
+			os.Unsetenv(k)
+		}(k)
+	}
+
+	// Run
+	c := Config{
+		Handler: "cancel",
+		Client: &MockHttpClient{
+			MockDo: func(req *http.Request) (*http.Response, error) {
+				traceparent := req.Header.Get("traceparent")
+				require.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$", traceparent)
+				require.NotEqual(t, env["TRACEPARENT"], traceparent)
+				return &http.Response{
+					StatusCode: 200,
+					Status:     "200 OK",
+					Body:       io.NopCloser(bytes.NewBufferString(`{}`)),
+				}, nil
+			},
+		},
+		Output: &MockStdOut{
+			MockPrintf:  func(format string, a ...any) {},
+			MockPrintln: func(a ...any) {},
+		},
+	}
+	err := c.Run(context.Background())
+
+	// Verify
+	require.NoError(t, err)
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	request, handler, run := spans[0], spans[1], spans[2]
+	require.Equal(t, "HTTP POST", request.Name())
+	require.Equal(t, trace.SpanKindClient, request.SpanKind())
+	require.Contains(t, request.Attributes(), attribute.Int("http.response.status_code", 200))
+	require.Equal(t, "cancel", handler.Name())
+	require.Contains(t, handler.Attributes(), attribute.String("approval.cancellation_reason", "TIMED_OUT"))
+	require.Equal(t, "manual-approval", run.Name())
+	require.Contains(t, run.Attributes(), attribute.String("handler", "cancel"))
+
+	require.Equal(t, handler.SpanContext().SpanID(), request.Parent().SpanID())
+	require.Equal(t, run.SpanContext().SpanID(), handler.Parent().SpanID())
+	require.Equal(t, "00f067aa0ba902b7", run.Parent().SpanID().String())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", run.SpanContext().TraceID().String())
+}
+
+func Test_setupTracing(t *testing.T) {
+	defer func(tp trace.TracerProvider) {
+		otel.SetTracerProvider(tp)
+	}(otel.GetTracerProvider())
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		err  string
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name: "file",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "file", "OTEL_TRACES_FILE": filepath.Join(t.TempDir(), "traces.json")},
+		},
+		{
+			name: "file without path",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "file"},
+			err:  "failed to create traces exporter: OTEL_TRACES_FILE environment variable missing",
+		},
+		{
+			name: "unsupported exporter",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "zipkin"},
+			err:  "unsupported traces exporter 'zipkin', valid values are: none, otlp, file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+
+			// Run
+			shutdown, err := setupTracing(context.Background())
+
+			// Verify
+			if tt.err != "" {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			_, span := tracer().Start(context.Background(), "test")
+			span.End()
+			require.NoError(t, shutdown(context.Background()))
+
+			if file := tt.env["OTEL_TRACES_FILE"]; file != "" {
+				out, ferr := os.ReadFile(file)
+				require.NoError(t, ferr)
+				require.Contains(t, string(out), `"Name":"test"`)
+			}
+		})
+	}
+}
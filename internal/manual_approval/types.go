@@ -21,10 +21,17 @@ type Config struct {
 
 	// Handler field allows you to handler.
 	Handler string `json:"handler,omitempty"`
+
+	// DryRun prints the API requests, status and outputs instead of sending and writing them.
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 type CreateManualApprovalResponse struct {
+	Id        string      `json:"id,omitempty"`
+	Url       string      `json:"url,omitempty"`
 	Approvers []Approvers `json:"approvers"`
+	// ApproverGroups is only returned by a platform which requires an approval of each group
+	ApproverGroups [][]string `json:"approverGroups,omitempty"`
 }
 
 type Approvers struct {
@@ -32,3 +39,52 @@ type Approvers struct {
 	UserId   string `json:"userId"`
 	Email    string `json:"email"`
 }
+
+// ApproverKind tells the platform whether a requested approver is a user or a team
+type ApproverKind struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type ValidateApproversResponse struct {
+	Approvers []ValidatedApprover `json:"approvers"`
+}
+
+// ValidatedApprover is a requested approver resolved against the platform users and teams
+type ValidatedApprover struct {
+	Approver             string `json:"approver"`
+	Found                bool   `json:"found"`
+	Type                 string `json:"type"`
+	UserName             string `json:"userName"`
+	UserId               string `json:"userId"`
+	Email                string `json:"email"`
+	TeamName             string `json:"teamName"`
+	HasExecutePermission bool   `json:"hasExecutePermission"`
+}
+
+type PendingApprovalsResponse struct {
+	Approvals []PendingApproval `json:"approvals"`
+}
+
+// PendingApproval is an approval request which is still waiting for a decision
+type PendingApproval struct {
+	Id          string `json:"id"`
+	Url         string `json:"url,omitempty"`
+	RequestedOn string `json:"requestedOn,omitempty"`
+}
+
+type ApprovalDecisionsResponse struct {
+	Decisions []ApprovalDecision `json:"decisions"`
+}
+
+// ApprovalDecision is the decision of an approver on an earlier approval request
+type ApprovalDecision struct {
+	Id          string        `json:"id"`
+	Url         string        `json:"url,omitempty"`
+	UserId      string        `json:"userId,omitempty"`
+	UserName    string        `json:"userName"`
+	Email       string        `json:"email,omitempty"`
+	Comments    string        `json:"comments"`
+	RespondedOn string        `json:"respondedOn"`
+	Inputs      []interface{} `json:"inputs,omitempty"`
+}
@@ -0,0 +1,195 @@
+package manual_approval
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Name of the approval input generated for the reasonCodes
+const reasonCodeInput = "reasonCode"
+
+// Decisions an approver can make, as used by requireComment
+const (
+	decisionApproved = "approved"
+	decisionRejected = "rejected"
+)
+
+// Check the approver's response against the approval policies of the manual approval job
+func validateResponse(approvalStatus string, comments string, inputDefs []inputDefinition, inputValues map[string]interface{}) error {
+	decision := decisionFromStatus(approvalStatus)
+
+	if err := validateInputValues(inputDefs, inputValues); err != nil {
+		return err
+	}
+
+	requireComment, err := parseRequireComment(os.Getenv("REQUIRE_COMMENT"))
+	if err != nil {
+		return err
+	}
+	if requireComment[decision] && strings.TrimSpace(comments) == "" {
+		return fmt.Errorf("a comment is required when the request is %s", decision)
+	}
+
+	if reasonCodes := parseList(os.Getenv("REASON_CODES")); len(reasonCodes) > 0 {
+		reasonCode, _ := inputValues[reasonCodeInput].(string)
+		if reasonCode == "" {
+			return fmt.Errorf("a reason code is required, valid values are: %s", strings.Join(reasonCodes, ", "))
+		}
+		if !slices.Contains(reasonCodes, reasonCode) {
+			return fmt.Errorf("unknown reason code '%s', valid values are: %s", reasonCode, strings.Join(reasonCodes, ", "))
+		}
+	}
+
+	enforceChecklist, err := boolEnv("ENFORCE_CHECKLIST")
+	if err != nil {
+		return err
+	}
+	if enforceChecklist && decision == decisionApproved {
+		if err := validateChecklist(inputDefs, inputValues); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check the user who responded against the disallowUsers, so the separation of duties
+// is enforced beyond the user who launched the workflow
+func (k *Config) validateResponder(userId string, userName string, email string) error {
+	users, err := k.disallowedUsers(os.Getenv("DISALLOW_USERS"), os.Getenv("DISALLOW_USERS_VARS"))
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if (userId != "" && strings.EqualFold(user.identity, userId)) || (email != "" && strings.EqualFold(user.identity, email)) {
+			return fmt.Errorf("user '%s' (%s) is not allowed to respond to this approval request as it matches the disallowUsers entry '%s'", userName, user.identity, user.entry)
+		}
+	}
+	return nil
+}
+
+// A user ID or email which is not allowed to respond, along with the disallowUsers entry it comes from
+type disallowedUser struct {
+	identity string
+	entry    string
+}
+
+// Parse the disallowUsers. Entries in the form $NAME or ${NAME} are replaced with the comma or
+// newline separated identities in the variable NAME of the disallowUsersVars, or else in the
+// environment variable NAME. Entries replaced with no identity are written as warnings, as the
+// callback handler only gets the environment variables of the custom job.
+func (k *Config) disallowedUsers(value string, varsJson string) ([]disallowedUser, error) {
+	vars := make(map[string]string)
+	if strings.TrimSpace(varsJson) != "" {
+		if err := json.Unmarshal([]byte(varsJson), &vars); err != nil {
+			return nil, fmt.Errorf("disallowUsersVars must be a JSON object of strings: %w", err)
+		}
+	}
+	lookup := func(name string) string {
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return os.Getenv(name)
+	}
+
+	var users []disallowedUser
+	for _, entry := range parseList(value) {
+		if !strings.HasPrefix(entry, "$") {
+			users = append(users, disallowedUser{identity: entry, entry: entry})
+			continue
+		}
+
+		identities := parseList(os.Expand(entry, lookup))
+		if len(identities) == 0 {
+			k.Output.Printf("WARNING: disallowUsers entry '%s' is replaced with no user, set the variable in disallowUsersVars\n", entry)
+		}
+		for _, identity := range identities {
+			users = append(users, disallowedUser{identity: identity, entry: entry})
+		}
+	}
+	return users, nil
+}
+
+func decisionFromStatus(approvalStatus string) string {
+	switch approvalStatus {
+	case "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED":
+		return decisionApproved
+	case "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED":
+		return decisionRejected
+	default:
+		return ""
+	}
+}
+
+// Get the decisions which require a comment, e.g. "rejected" or "approved,rejected"
+func parseRequireComment(value string) (map[string]bool, error) {
+	required := make(map[string]bool)
+	for _, entry := range parseList(strings.ToLower(value)) {
+		switch entry {
+		case "true", "all":
+			required[decisionApproved] = true
+			required[decisionRejected] = true
+		case "false", "none":
+		case decisionApproved, decisionRejected:
+			required[entry] = true
+		default:
+			return nil, fmt.Errorf("unsupported requireComment value '%s', valid values are: %s, %s, all, none", entry, decisionApproved, decisionRejected)
+		}
+	}
+	return required, nil
+}
+
+// Approval input generated for the reasonCodes
+func reasonCodeInputDefinition(reasonCodes []string) inputDefinition {
+	return inputDefinition{
+		Name:        reasonCodeInput,
+		Type:        "choice",
+		Required:    true,
+		Description: "Reason for the decision",
+		Options:     reasonCodes,
+	}
+}
+
+// Every checklist item must be ticked before approving. The items are the checklist inputs declared
+// when the approval was requested, so an item left out of the response is not acknowledged.
+func validateChecklist(inputDefs []inputDefinition, inputValues map[string]interface{}) error {
+	var names []string
+	for _, def := range inputDefs {
+		if strings.HasPrefix(def.Name, checklistInputPrefix) {
+			names = append(names, def.Name)
+		}
+	}
+	for name := range inputValues {
+		if strings.HasPrefix(name, checklistInputPrefix) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, compareChecklistItems)
+
+	for _, name := range names {
+		if inputValues[name] != true {
+			return fmt.Errorf("checklist item '%s' must be acknowledged before approving", name)
+		}
+	}
+	return nil
+}
+
+// Order the checklist inputs by item number, so that checklist-2 comes before checklist-10
+func compareChecklistItems(a string, b string) int {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, checklistInputPrefix))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, checklistInputPrefix))
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
@@ -0,0 +1,232 @@
+package manual_approval
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		approvalStatus string
+		comments       string
+		inputDefs      []inputDefinition
+		inputValues    map[string]interface{}
+		err            string
+	}{
+		{
+			name:           "no policies",
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+		},
+		{
+			name:           "comment required on rejection",
+			env:            map[string]string{"REQUIRE_COMMENT": "rejected"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+			comments:       "  ",
+			err:            "a comment is required when the request is rejected",
+		},
+		{
+			name:           "comment not required on approval",
+			env:            map[string]string{"REQUIRE_COMMENT": "rejected"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+		},
+		{
+			name:           "comment required on every decision",
+			env:            map[string]string{"REQUIRE_COMMENT": "true"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			err:            "a comment is required when the request is approved",
+		},
+		{
+			name:           "comment provided",
+			env:            map[string]string{"REQUIRE_COMMENT": "approved, rejected"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			comments:       "lgtm",
+		},
+		{
+			name:           "invalid requireComment",
+			env:            map[string]string{"REQUIRE_COMMENT": "sometimes"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			err:            "unsupported requireComment value 'sometimes', valid values are: approved, rejected, all, none",
+		},
+		{
+			name:           "reason code",
+			env:            map[string]string{"REASON_CODES": "planned,hotfix"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			inputValues:    map[string]interface{}{"reasonCode": "hotfix"},
+		},
+		{
+			name:           "missing reason code",
+			env:            map[string]string{"REASON_CODES": "planned,hotfix"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			inputValues:    map[string]interface{}{},
+			err:            "a reason code is required, valid values are: planned, hotfix",
+		},
+		{
+			name:           "unknown reason code",
+			env:            map[string]string{"REASON_CODES": "planned\nhotfix"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+			inputValues:    map[string]interface{}{"reasonCode": "whim"},
+			err:            "unknown reason code 'whim', valid values are: planned, hotfix",
+		},
+		{
+			name:           "checklist acknowledged",
+			env:            map[string]string{"ENFORCE_CHECKLIST": "true"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			inputDefs:      checklistInputs([]string{"Rollback plan verified", "Release notes written"}),
+			inputValues:    map[string]interface{}{"checklist-1": true, "checklist-2": true},
+		},
+		{
+			name:           "checklist item missing from the response",
+			env:            map[string]string{"ENFORCE_CHECKLIST": "true"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			inputDefs:      checklistInputs([]string{"Rollback plan verified", "Release notes written"}),
+			inputValues:    map[string]interface{}{"checklist-1": true},
+			err:            "checklist item 'checklist-2' must be acknowledged before approving",
+		},
+		{
+			name:           "checklist items in numeric order",
+			env:            map[string]string{"ENFORCE_CHECKLIST": "true"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_APPROVED",
+			inputDefs:      checklistInputs(make([]string, 10)),
+			inputValues:    map[string]interface{}{"checklist-1": true, "checklist-3": true, "checklist-4": true, "checklist-5": true, "checklist-6": true, "checklist-7": true, "checklist-8": true, "checklist-9": true},
+			err:            "checklist item 'checklist-2' must be acknowledged before approving",
+		},
+		{
+			name:           "checklist not enforced on rejection",
+			env:            map[string]string{"ENFORCE_CHECKLIST": "true"},
+			approvalStatus: "UPDATE_MANUAL_APPROVAL_STATUS_REJECTED",
+			inputDefs:      checklistInputs([]string{"Rollback plan verified"}),
+			inputValues:    map[string]interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+
+			// Run
+			err := validateResponse(tt.approvalStatus, tt.comments, tt.inputDefs, tt.inputValues)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_validateResponder(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		userId   string
+		userName string
+		email    string
+		output   []string
+		err      string
+	}{
+		{
+			name:     "no disallowed users",
+			userId:   "123",
+			userName: "testUserName",
+			email:    "user@mail.com",
+		},
+		{
+			name:     "user ID",
+			env:      map[string]string{"DISALLOW_USERS": "456, 123"},
+			userId:   "123",
+			userName: "testUserName",
+			email:    "user@mail.com",
+			err:      "user 'testUserName' (123) is not allowed to respond to this approval request as it matches the disallowUsers entry '123'",
+		},
+		{
+			name:     "email is case insensitive",
+			env:      map[string]string{"DISALLOW_USERS": "User@Mail.com"},
+			userId:   "123",
+			userName: "testUserName",
+			email:    "user@mail.com",
+			err:      "user 'testUserName' (User@Mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry 'User@Mail.com'",
+		},
+		{
+			name:     "environment variable",
+			env:      map[string]string{"DISALLOW_USERS": "other@mail.com\n${GIT_AUTHORS}", "GIT_AUTHORS": "author@mail.com,user@mail.com"},
+			userId:   "123",
+			userName: "testUserName",
+			email:    "user@mail.com",
+			err:      "user 'testUserName' (user@mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry '${GIT_AUTHORS}'",
+		},
+		{
+			name:     "variable of disallowUsersVars",
+			env:      map[string]string{"DISALLOW_USERS": "$GIT_AUTHORS", "DISALLOW_USERS_VARS": `{"GIT_AUTHORS":"user@mail.com"}`, "GIT_AUTHORS": "author@mail.com"},
+			userId:   "123",
+			userName: "testUserName",
+			email:    "user@mail.com",
+			err:      "user 'testUserName' (user@mail.com) is not allowed to respond to this approval request as it matches the disallowUsers entry '$GIT_AUTHORS'",
+		},
+		{
+			name:     "unset variable",
+			env:      map[string]string{"DISALLOW_USERS": "$GIT_AUTHORS"},
+			userId:   "123",
+			userName: "testUserName",
+			email:    "user@mail.com",
+			output:   []string{"WARNING: disallowUsers entry '$GIT_AUTHORS' is replaced with no user, set the variable in disallowUsersVars\n"},
+		},
+		{
+			name:     "invalid disallowUsersVars",
+			env:      map[string]string{"DISALLOW_USERS": "$GIT_AUTHORS", "DISALLOW_USERS_VARS": `["user@mail.com"]`},
+			userId:   "123",
+			userName: "testUserName",
+			err:      "disallowUsersVars must be a JSON object of strings: json: cannot unmarshal array into Go value of type map[string]string",
+		},
+		{
+			name:     "other user",
+			env:      map[string]string{"DISALLOW_USERS": "456,author@mail.com"},
+			userId:   "123",
+			userName: "testUserName",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer func(k string) {
+					os.Unsetenv(k)
+				}(k)
+			}
+
+			var testOutput []string
+			c := Config{
+				Output: &MockStdOut{
+					MockPrintf: func(format string, a ...any) {
+						testOutput = append(testOutput, fmt.Sprintf(format, a...))
+					},
+				},
+			}
+
+			// Run
+			err := c.validateResponder(tt.userId, tt.userName, tt.email)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+			require.Equal(t, tt.output, testOutput)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package manual_approval
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_formatValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  interface{}
+		output string
+		err    string
+	}{
+		{name: "nil", input: nil, output: ""},
+		{name: "string", input: "text\nwith newline", output: "text\nwith newline"},
+		{name: "bool", input: true, output: "true"},
+		{name: "int", input: 42, output: "42"},
+		{name: "negative int64", input: int64(-9007199254740993), output: "-9007199254740993"},
+		{name: "float", input: 99.33, output: "99.33"},
+		{name: "large float", input: 1e21, output: "1000000000000000000000"},
+		{name: "json.Number integer", input: json.Number("9007199254740993"), output: "9007199254740993"},
+		{name: "json.Number decimal", input: json.Number("99.330"), output: "99.330"},
+		{name: "list", input: []interface{}{"eu", json.Number("1"), true, nil}, output: `["eu",1,true,null]`},
+		{name: "nested object", input: map[string]interface{}{"b": []interface{}{"<x>"}, "a": map[string]interface{}{"d": 1, "c": 2}}, output: `{"a":{"c":2,"d":1},"b":["<x>"]}`},
+		{name: "unsupported", input: struct{}{}, err: "unsupported value type struct {}"},
+		{name: "unsupported nested", input: []interface{}{make(chan int)}, err: "json: unsupported type: chan int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Run
+			result, err := formatValue(tt.input)
+
+			// Verify
+			if tt.err == "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.output, result)
+			} else {
+				require.Error(t, err)
+				require.Equal(t, tt.err, err.Error())
+			}
+		})
+	}
+}
+
+func Test_decodeJSON(t *testing.T) {
+	parsed := map[string]interface{}{}
+	err := decodeJSON(`{"inputs":[{"name":"build","value":9007199254740993}]}`, &parsed)
+	require.NoError(t, err)
+
+	value := parsed["inputs"].([]interface{})[0].(map[string]interface{})["value"]
+	require.Equal(t, json.Number("9007199254740993"), value)
+
+	result, err := formatValue(value)
+	require.NoError(t, err)
+	require.Equal(t, "9007199254740993", result)
+}